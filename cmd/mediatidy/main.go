@@ -1,14 +1,23 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"errors"
+	"fmt"
 	"github.com/itsjavi/mediatidy/internal/app"
 	"github.com/urfave/cli/v2"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 )
 
+// verbosity is the number of times the verbose flag is repeated
+var verbosity int
+
 func main() {
 	var cliApp = &cli.App{
 		Usage:                  "Media file organizer",
@@ -16,6 +25,18 @@ func main() {
 		ArgsUsage:              "source destination",
 		UseShortOptionHandling: true,
 		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "config",
+				Value:   "",
+				Aliases: []string{},
+				Usage:   "Path to a YAML config file. Defaults to ~/.config/mediatidy/config.yaml, if it exists.",
+			},
+			&cli.StringFlag{
+				Name:    "profile",
+				Value:   "",
+				Aliases: []string{"p"},
+				Usage:   "Name of the config file profile to apply, e.g. \"phone-import\".",
+			},
 			&cli.BoolFlag{
 				Name:    "dry-run",
 				Value:   false,
@@ -50,7 +71,194 @@ func main() {
 				Name:    "move",
 				Value:   false,
 				Aliases: []string{"m"},
-				Usage:   "Move the files instead of copying them to the destination.",
+				Usage:   "Move the files instead of copying them to the destination. Same as --mode move.",
+			},
+			&cli.StringFlag{
+				Name:    "mode",
+				Value:   "",
+				Aliases: []string{},
+				Usage: "How to put the files in the destination: copy, move, hardlink, symlink or reflink (default \"copy\"). " +
+					"Links and clones fall back to a copy when they are not supported, e.g. across filesystems.",
+			},
+			&cli.BoolFlag{
+				Name:    "separate-raw",
+				Value:   false,
+				Aliases: []string{"r"},
+				Usage:   "Organize camera RAW files (CR2, NEF, ARW, DNG, etc.) in their own \"raw\" folder.",
+			},
+			&cli.BoolFlag{
+				Name:    "fix-extensions",
+				Value:   false,
+				Aliases: []string{"e"},
+				Usage:   "Use the real file extension in the destination, detected by the file content, e.g. for JPEG files saved as .png.",
+			},
+			&cli.StringSliceFlag{
+				Name:    "route",
+				Aliases: []string{},
+				Usage: "Organize a category of files in its own directory, e.g. \"screenshot=screenshots\". " +
+					"Categories: screenshot, messaging, edited. Can be repeated.",
+			},
+			&cli.StringFlag{
+				Name:    "dest-pattern",
+				Value:   "",
+				Aliases: []string{},
+				Usage: "Destination directory pattern (default \"{media}/{year}/{month}\"). Placeholders: {media}, {year}, " +
+					"{month}, {day}, {country}, {country_code}, {region}, {city}, {camera}, {category}, {type}.",
+			},
+			&cli.Float64Flag{
+				Name:    "geocode-max-distance",
+				Value:   app.DefaultGeocodeMaxDistance,
+				Aliases: []string{},
+				Usage:   "Maximum distance in km to the nearest known city to resolve the location of geotagged files.",
+			},
+			&cli.StringSliceFlag{
+				Name:    "gpx",
+				Aliases: []string{},
+				Usage:   "GPS track log used to geotag the files without GPS position, by their creation time. Can be repeated.",
+			},
+			&cli.StringFlag{
+				Name:    "gpx-max-gap",
+				Value:   "",
+				Aliases: []string{},
+				Usage:   "Maximum time difference between a file and the track points to geotag it (default \"30m\").",
+			},
+			&cli.BoolFlag{
+				Name:    "gpx-write",
+				Value:   false,
+				Aliases: []string{},
+				Usage:   "Write the GPS position of the files geotagged from the track logs into the destination files.",
+			},
+			&cli.StringFlag{
+				Name:    "source-type",
+				Value:   "",
+				Aliases: []string{},
+				Usage: "Type of source: dir or dcim (default \"dir\"). With dcim, only the DCIM directory of a memory card " +
+					"or phone is imported, and the card volume is recorded in the metadata.",
+			},
+			&cli.BoolFlag{
+				Name:    "only-new",
+				Value:   false,
+				Aliases: []string{},
+				Usage:   "Skip the files imported from the same card before, with the dcim source type.",
+			},
+			&cli.StringFlag{
+				Name:    "timezone",
+				Value:   "",
+				Aliases: []string{"tz"},
+				Usage:   "Default timezone of the files without GPS or timezone info, e.g. \"Europe/Madrid\" or \"+02:00\".",
+			},
+			&cli.StringSliceFlag{
+				Name:    "camera-timezone",
+				Aliases: []string{},
+				Usage:   "Timezone of the clock of a camera model, e.g. \"Canon EOS R6=+09:00\". Can be repeated.",
+			},
+			&cli.StringFlag{
+				Name:    "trips",
+				Value:   "",
+				Aliases: []string{},
+				Usage:   "Path to a YAML file with a list of trips, mapping date ranges to the timezone the files were taken in.",
+			},
+			&cli.StringSliceFlag{
+				Name:    "time-shift",
+				Aliases: []string{},
+				Usage: "Correct the clock of a camera, optionally only between two dates, " +
+					"e.g. \"Canon EOS R6=-1h\" or \"Canon EOS R6@2023-05-01..2023-05-20=+1h30m\". Can be repeated.",
+			},
+			&cli.StringSliceFlag{
+				Name:    "include",
+				Aliases: []string{"i"},
+				Usage:   "Only process files matching this gitignore-style pattern, e.g. \"DCIM/**\". Can be repeated.",
+			},
+			&cli.StringSliceFlag{
+				Name:    "exclude",
+				Aliases: []string{"x"},
+				Usage: "Skip files and directories matching this gitignore-style pattern, e.g. \"@eaDir/\". Can be repeated. " +
+					"Patterns are also read from the .mediatidyignore file of every directory.",
+			},
+			&cli.StringFlag{
+				Name:    "min-size",
+				Value:   "",
+				Aliases: []string{},
+				Usage:   "Skip files smaller than this size, e.g. \"50KB\".",
+			},
+			&cli.StringFlag{
+				Name:    "max-size",
+				Value:   "",
+				Aliases: []string{},
+				Usage:   "Skip files larger than this size, e.g. \"4GB\".",
+			},
+			&cli.StringFlag{
+				Name:    "since",
+				Value:   "",
+				Aliases: []string{},
+				Usage:   "Skip files created before this date (YYYY-MM-DD).",
+			},
+			&cli.StringFlag{
+				Name:    "until",
+				Value:   "",
+				Aliases: []string{},
+				Usage:   "Skip files created after this date (YYYY-MM-DD).",
+			},
+			&cli.BoolFlag{
+				Name:    "verbose",
+				Value:   false,
+				Aliases: []string{"v"},
+				Count:   &verbosity,
+				Usage:   "Print what happens with every file, including the reason why it was skipped. Use -vv to also print the scanned files.",
+			},
+			&cli.StringFlag{
+				Name:    "log-file",
+				Value:   "",
+				Aliases: []string{},
+				Usage:   "Append the log of what happened with every file to this file, regardless of the verbosity.",
+			},
+			&cli.StringFlag{
+				Name:    "log-format",
+				Value:   "",
+				Aliases: []string{},
+				Usage:   "Format of the log: text or json (default \"text\").",
+			},
+			&cli.StringFlag{
+				Name:    "report",
+				Value:   "",
+				Aliases: []string{},
+				Usage:   "Write the report of the run to this file, instead of printing it at the end.",
+			},
+			&cli.StringFlag{
+				Name:    "report-format",
+				Value:   "",
+				Aliases: []string{},
+				Usage:   "Format of the report: text, json, markdown or html (default \"text\").",
+			},
+			&cli.StringFlag{
+				Name:    "duplicates",
+				Value:   "",
+				Aliases: []string{},
+				Usage:   "Write the list of source files that were already in the destination to this file.",
+			},
+			&cli.StringFlag{
+				Name:    "duplicates-format",
+				Value:   "",
+				Aliases: []string{},
+				Usage:   "Format of the duplicates list: csv or json (default \"csv\").",
+			},
+			&cli.StringFlag{
+				Name:    "duplicates-action",
+				Value:   "",
+				Aliases: []string{},
+				Usage:   "What to do with the source duplicates after the run: quarantine or delete. It asks for confirmation first.",
+			},
+			&cli.StringFlag{
+				Name:    "quarantine-dir",
+				Value:   "",
+				Aliases: []string{},
+				Usage:   "Directory where the source duplicates are moved to (default \"<destination>/.quarantine\").",
+			},
+			&cli.BoolFlag{
+				Name:    "yes",
+				Value:   false,
+				Aliases: []string{"y"},
+				Usage:   "Do not ask for confirmation.",
 			},
 			&cli.BoolFlag{
 				Name:    "quiet",
@@ -59,9 +267,493 @@ func main() {
 				Usage:   "It won't print anything, unless it's an error.",
 			},
 		},
-		Action: func(c *cli.Context) error {
-			params := app.CmdOptions{}
+		Commands: []*cli.Command{
+			{
+				Name:      "estimate-skew",
+				Usage:     "Estimate the clock difference between two cameras, from their geotagged files taken at the same place",
+				ArgsUsage: "source",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "camera",
+						Required: true,
+						Usage:    "Camera model whose clock is off, as found in the CameraModel metadata.",
+					},
+					&cli.StringFlag{
+						Name:     "reference",
+						Required: true,
+						Usage:    "Camera model with the right clock, e.g. a phone.",
+					},
+					&cli.Float64Flag{
+						Name:  "max-distance",
+						Value: 200,
+						Usage: "Maximum distance in meters between two files to be considered taken at the same place.",
+					},
+					&cli.DurationFlag{
+						Name:  "max-gap",
+						Value: 3 * time.Hour,
+						Usage: "Maximum time difference between two files to be considered taken at the same time.",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return errors.New("Source directory argument is missing.")
+					}
+
+					params, err := loadCmdOptions(c)
+					if app.IsError(err) {
+						return err
+					}
+
+					params.CurrentTime = time.Now()
+					params.SrcDir, _ = filepath.Abs(c.Args().Get(0))
+
+					if !app.IsDir(params.SrcDir) {
+						return errors.New("Source directory does not exist.")
+					}
+
+					logCloser, err := app.SetupLogger(params)
+					if app.IsError(err) {
+						return err
+					}
+					defer logCloser.Close()
+
+					ctx, stop := signalContext()
+					defer stop()
+
+					estimate, err := app.EstimateClockSkew(ctx, params, c.String("camera"), c.String("reference"),
+						c.Float64("max-distance"), c.Duration("max-gap"))
+					if ctx.Err() != nil {
+						return cli.Exit("", app.ExitCodeInterrupted)
+					}
+					if app.IsError(err) {
+						return err
+					}
+
+					app.PrintLn("%s is %s ahead of %s (estimated from %d file pairs).",
+						estimate.Camera, estimate.Skew.Round(time.Second), estimate.Reference, estimate.Pairs)
+					app.PrintLn("Use --time-shift \"%s\" to correct it.", estimate.TimeShift())
+
+					return nil
+				},
+			},
+			{
+				Name:      "dupes",
+				Usage:     "Find the duplicated media files of a directory, without importing anything",
+				ArgsUsage: "source",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "similar",
+						Usage: "Also group near-identical JPEG, PNG and GIF images, e.g. resized or recompressed copies.",
+					},
+					&cli.IntFlag{
+						Name:  "similarity",
+						Value: 5,
+						Usage: "Maximum number of different bits (0-64) between the hashes of two similar images.",
+					},
+					&cli.StringFlag{
+						Name:  "action",
+						Usage: "Replace the redundant copies with a hardlink or symlink to the kept file, or delete them: hardlink, symlink or delete. It asks for confirmation first.",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return errors.New("Source directory argument is missing.")
+					}
+
+					params, err := loadCmdOptions(c)
+					if app.IsError(err) {
+						return err
+					}
+
+					params.CurrentTime = time.Now()
+					params.SrcDir, _ = filepath.Abs(c.Args().Get(0))
+
+					if !app.IsDir(params.SrcDir) {
+						return errors.New("Source directory does not exist.")
+					}
+
+					if err := app.ValidateDupesAction(c.String("action")); app.IsError(err) {
+						return err
+					}
+
+					logCloser, err := app.SetupLogger(params)
+					if app.IsError(err) {
+						return err
+					}
+					defer logCloser.Close()
+
+					ctx, stop := signalContext()
+					defer stop()
+
+					groups, err := app.FindDuplicates(ctx, params, c.Bool("similar"), c.Int("similarity"))
+					if ctx.Err() != nil {
+						return cli.Exit("", app.ExitCodeInterrupted)
+					}
+					if app.IsError(err) {
+						return err
+					}
+
+					var redundant int
+					var reclaimable int64
+					for i, group := range groups {
+						kind := "identical"
+						if group.Similar {
+							kind = "similar"
+						}
+						fmt.Printf("Group %d: %d %s files", i+1, len(group.Files), kind)
+						if !group.Similar {
+							fmt.Printf(", %s each, %s reclaimable", app.TotalBytesToString(group.Size, false),
+								app.TotalBytesToString(group.ReclaimableSize(), false))
+							redundant += len(group.Files) - 1
+							reclaimable += group.ReclaimableSize()
+						}
+						fmt.Println()
+						for j, path := range group.Files {
+							if j == 0 {
+								fmt.Printf("  %s (kept)\n", path)
+							} else {
+								fmt.Printf("  %s\n", path)
+							}
+						}
+					}
+
+					app.PrintLn("%d groups, %d redundant copies, %s reclaimable.", len(groups), redundant,
+						app.TotalBytesToString(reclaimable, false))
+
+					action := c.String("action")
+					if action == "" || redundant == 0 {
+						return nil
+					}
+
+					prompt := fmt.Sprintf("Replace %d redundant copies with a %s?", redundant, action)
+					if action == app.DupesActionDelete {
+						prompt = fmt.Sprintf("Delete %d redundant copies?", redundant)
+					}
+					if !params.DryRun && !c.Bool("yes") && !confirm(prompt) {
+						return nil
+					}
+
+					resolved, reclaimed, err := app.ResolveDuplicateGroups(ctx, params, groups, action)
+					if !params.DryRun {
+						app.PrintLn("%d redundant copies resolved (%s), %s reclaimed.", resolved, action,
+							app.TotalBytesToString(reclaimed, false))
+					}
+					if ctx.Err() != nil {
+						return cli.Exit("", app.ExitCodeInterrupted)
+					}
+
+					return err
+				},
+			},
+			{
+				Name:      "dedupe",
+				Usage:     "Deduplicate the identical files of an existing library",
+				ArgsUsage: "destination",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "keep",
+						Value: app.DedupeKeepEarliest,
+						Usage: "Which file of every group to keep: earliest (creation date), shortest-path or gps (with GPS position).",
+					},
+					&cli.StringFlag{
+						Name:  "action",
+						Value: app.DedupeActionTrash,
+						Usage: "What to do with the other files: trash (move them to the .trash directory of the library) or hardlink (to the kept file).",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return errors.New("Destination directory argument is missing.")
+					}
+
+					params, err := loadCmdOptions(c)
+					if app.IsError(err) {
+						return err
+					}
+
+					params.CurrentTime = time.Now()
+					params.DestDir, _ = filepath.Abs(c.Args().Get(0))
+
+					if !app.IsDir(params.DestDir) {
+						return errors.New("Destination directory does not exist.")
+					}
+
+					keep, action := c.String("keep"), c.String("action")
+					if err := app.ValidateDedupeOptions(keep, action); app.IsError(err) {
+						return err
+					}
+
+					logCloser, err := app.SetupLogger(params)
+					if app.IsError(err) {
+						return err
+					}
+					defer logCloser.Close()
+
+					ctx, stop := signalContext()
+					defer stop()
 
+					groups, err := app.FindLibraryDuplicates(ctx, params, params.DestDir, keep)
+					if ctx.Err() != nil {
+						return cli.Exit("", app.ExitCodeInterrupted)
+					}
+					if app.IsError(err) {
+						return err
+					}
+
+					var redundant int
+					var reclaimable int64
+					for _, group := range groups {
+						fmt.Printf("%s (kept, %s)\n", group.Kept, app.TotalBytesToString(group.Size, false))
+						for _, path := range group.Duplicates {
+							fmt.Printf("  %s\n", path)
+						}
+						redundant += len(group.Duplicates)
+						reclaimable += group.Size * int64(len(group.Duplicates))
+					}
+
+					app.PrintLn("%d groups, %d redundant files, %s reclaimable.", len(groups), redundant,
+						app.TotalBytesToString(reclaimable, false))
+
+					if redundant == 0 {
+						return nil
+					}
+
+					prompt := fmt.Sprintf("Move %d redundant files to the trash?", redundant)
+					if action == app.DedupeActionHardlink {
+						prompt = fmt.Sprintf("Replace %d redundant files with a hardlink?", redundant)
+					}
+					if !params.DryRun && !c.Bool("yes") && !confirm(prompt) {
+						return nil
+					}
+
+					deduplicated, reclaimed, err := app.DedupeLibrary(ctx, params, params.DestDir, groups, action)
+					if !params.DryRun {
+						app.PrintLn("%d files deduplicated (%s), %s reclaimed.", deduplicated, action,
+							app.TotalBytesToString(reclaimed, false))
+					}
+					if ctx.Err() != nil {
+						return cli.Exit("", app.ExitCodeInterrupted)
+					}
+
+					return err
+				},
+			},
+			{
+				Name:      "merge",
+				Usage:     "Merge a library into another one, using its metadata files",
+				ArgsUsage: "destination library",
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return errors.New("Destination and library directory arguments are missing.")
+					}
+					if c.NArg() < 2 {
+						return errors.New("Library directory argument is missing.")
+					}
+
+					params, err := loadCmdOptions(c)
+					if app.IsError(err) {
+						return err
+					}
+
+					params.CurrentTime = time.Now()
+					params.DestDir, _ = filepath.Abs(c.Args().Get(0))
+					params.SrcDir, _ = filepath.Abs(c.Args().Get(1))
+
+					if !app.IsDir(params.DestDir) {
+						return errors.New("Destination directory does not exist.")
+					}
+					if !app.IsDir(params.SrcDir) {
+						return errors.New("Library directory does not exist.")
+					}
+					if params.SrcDir == params.DestDir {
+						return errors.New("Destination and library directories cannot be the same.")
+					}
+
+					logCloser, err := app.SetupLogger(params)
+					if app.IsError(err) {
+						return err
+					}
+					defer logCloser.Close()
+
+					ctx, stop := signalContext()
+					defer stop()
+
+					app.Logger.Info("merge started", "library", params.SrcDir, "destination", params.DestDir,
+						"dry_run", params.DryRun)
+					stats, err := app.MergeLibrary(ctx, params)
+					app.Logger.Info("merge finished", "merged", stats.ProcessedFiles, "skipped", stats.SkippedFiles,
+						"duplicates", stats.DuplicatedFiles, "failed", stats.FailedFiles, "total_size", stats.TotalSize,
+						"interrupted", stats.Interrupted)
+					if app.IsError(err) {
+						return err
+					}
+
+					if err := writeReport(params, stats); app.IsError(err) {
+						return err
+					}
+					if stats.Interrupted {
+						return cli.Exit("", app.ExitCodeInterrupted)
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:      "watch",
+				Usage:     "Keep importing the files added to the source directory, until stopped",
+				ArgsUsage: "source destination",
+				Flags: []cli.Flag{
+					&cli.DurationFlag{
+						Name:  "stable-time",
+						Value: app.DefaultWatchStableTime,
+						Usage: "How long the size of a new file must not change before importing it.",
+					},
+					&cli.DurationFlag{
+						Name:  "poll-interval",
+						Value: app.DefaultWatchPollInterval,
+						Usage: "How often the source directory is scanned, when inotify is not available.",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return errors.New("Source and destination directory arguments are missing.")
+					}
+					if c.NArg() < 2 {
+						return errors.New("Destination directory argument is missing.")
+					}
+					if c.Duration("stable-time") <= 0 || c.Duration("poll-interval") <= 0 {
+						return errors.New("The stable time and poll interval must be positive durations.")
+					}
+
+					params, err := loadCmdOptions(c)
+					if app.IsError(err) {
+						return err
+					}
+
+					params.CurrentTime = time.Now()
+					params.SrcDir, _ = filepath.Abs(c.Args().Get(0))
+					params.DestDir, _ = filepath.Abs(c.Args().Get(1))
+
+					if !app.IsDir(params.SrcDir) {
+						return errors.New("Source directory does not exist.")
+					}
+
+					if params.SrcDir == params.DestDir {
+						return errors.New("Source and destination directories cannot be the same.")
+					}
+
+					logCloser, err := app.SetupLogger(params)
+					if app.IsError(err) {
+						return err
+					}
+					defer logCloser.Close()
+
+					ctx, stop := signalContext()
+					defer stop()
+
+					if !params.Quiet {
+						app.PrintLn("Watching %s, press Ctrl+C to stop.", params.SrcDir)
+					}
+
+					app.Logger.Info("watch started", "source", params.SrcDir, "destination", params.DestDir,
+						"dry_run", params.DryRun)
+					stats, err := app.Watch(ctx, params, c.Duration("stable-time"), c.Duration("poll-interval"))
+					app.Logger.Info("watch finished", "processed", stats.ProcessedFiles, "skipped", stats.SkippedFiles,
+						"duplicates", stats.DuplicatedFiles, "failed", stats.FailedFiles, "total_size", stats.TotalSize)
+					if app.IsError(err) {
+						return err
+					}
+
+					return writeReport(params, stats)
+				},
+			},
+			{
+				Name:      "export-geo",
+				Usage:     "Export the geotagged files of a library as a map layer (GeoJSON, KML or GPX)",
+				ArgsUsage: "destination",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Value: app.GeoFormatGeoJSON,
+						Usage: "Output format: geojson, kml or gpx.",
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "Output file. By default it's printed to the standard output.",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return errors.New("Destination directory argument is missing.")
+					}
+
+					params, err := loadCmdOptions(c)
+					if app.IsError(err) {
+						return err
+					}
+
+					params.CurrentTime = time.Now()
+					params.DestDir, _ = filepath.Abs(c.Args().Get(0))
+
+					if !app.IsDir(params.DestDir) {
+						return errors.New("Destination directory does not exist.")
+					}
+
+					logCloser, err := app.SetupLogger(params)
+					if app.IsError(err) {
+						return err
+					}
+					defer logCloser.Close()
+
+					out := os.Stdout
+					if c.String("output") != "" {
+						out, err = os.Create(c.String("output"))
+						if app.IsError(err) {
+							return err
+						}
+						defer out.Close()
+					}
+
+					count, err := app.ExportGeo(params, params.DestDir, c.String("format"), out)
+					if app.IsError(err) {
+						return err
+					}
+
+					if out != os.Stdout && !params.Quiet {
+						app.PrintLn("Exported %d geotagged files to %s.", count, c.String("output"))
+					}
+
+					return nil
+				},
+			},
+			{
+				Name:  "config",
+				Usage: "Inspect the configuration",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "show",
+						Usage: "Print the effective config, after merging the config file, profile and flags",
+						Action: func(c *cli.Context) error {
+							params, err := loadCmdOptions(c)
+							if app.IsError(err) {
+								return err
+							}
+
+							out, err := app.ConfigToYaml(params)
+							if app.IsError(err) {
+								return err
+							}
+
+							fmt.Print(string(out))
+
+							return nil
+						},
+					},
+				},
+			},
+		},
+		Action: func(c *cli.Context) error {
 			if c.NArg() == 0 {
 				return errors.New("Source and destination directory arguments are missing.")
 			}
@@ -69,16 +761,14 @@ func main() {
 				return errors.New("Destination directory argument is missing.")
 			}
 
+			params, err := loadCmdOptions(c)
+			if app.IsError(err) {
+				return err
+			}
+
 			params.CurrentTime = time.Now()
 			params.SrcDir, _ = filepath.Abs(c.Args().Get(0))
 			params.DestDir, _ = filepath.Abs(c.Args().Get(1))
-			params.DryRun = c.Bool("dry-run")
-			params.Limit = c.Uint("limit")
-			params.Extensions = c.String("extensions")
-			params.ConvertVideos = c.Bool("convert-videos")
-			params.FixDates = c.Bool("fix-dates")
-			params.Move = c.Bool("move")
-			params.Quiet = c.Bool("quiet")
 
 			if !app.IsDir(params.SrcDir) {
 				return errors.New("Source directory does not exist.")
@@ -88,11 +778,320 @@ func main() {
 				return errors.New("Source and destination directories cannot be the same.")
 			}
 
-			_, err := app.TidyUp(params)
+			logCloser, err := app.SetupLogger(params)
+			if app.IsError(err) {
+				return err
+			}
+			defer logCloser.Close()
 
-			return err
+			ctx, stop := signalContext()
+			defer stop()
+
+			app.Logger.Info("run started", "source", params.SrcDir, "destination", params.DestDir, "dry_run", params.DryRun)
+			stats, err := app.TidyUp(ctx, params)
+			app.Logger.Info("run finished", "processed", stats.ProcessedFiles, "skipped", stats.SkippedFiles,
+				"duplicates", stats.DuplicatedFiles, "failed", stats.FailedFiles, "total_size", stats.TotalSize,
+				"interrupted", stats.Interrupted)
+			if app.IsError(err) {
+				return err
+			}
+
+			if err := writeReport(params, stats); app.IsError(err) {
+				return err
+			}
+
+			// the duplicates are not resolved when the run didn't finish
+			if stats.Interrupted {
+				return cli.Exit("", app.ExitCodeInterrupted)
+			}
+
+			return handleDuplicates(ctx, params, stats, c.Bool("yes"))
 		},
 	}
 	err := cliApp.Run(os.Args)
 	app.HandleError(err)
 }
+
+// signalContext returns a context that is cancelled on SIGINT or SIGTERM, so the current file can be finished or
+// rolled back before exiting. A second signal stops the program right away.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-signals:
+			app.Logger.Warn("interrupted, finishing the current file", "signal", sig.String())
+			signal.Stop(signals)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
+// writeReport writes the report of the run to the report file, or prints it if there is none.
+func writeReport(params app.CmdOptions, stats app.CmdFileStats) error {
+	report := app.NewRunReport(params, stats)
+
+	if params.ReportFile == "" {
+		if params.Quiet {
+			return nil
+		}
+		return app.WriteReport(os.Stdout, report, params.ReportFormat)
+	}
+
+	f, err := os.Create(params.ReportFile)
+	if app.IsError(err) {
+		return err
+	}
+	defer f.Close()
+
+	if err := app.WriteReport(f, report, params.ReportFormat); app.IsError(err) {
+		return err
+	}
+
+	if !params.Quiet {
+		app.PrintLn("Report written to %s", params.ReportFile)
+	}
+
+	return f.Close()
+}
+
+// handleDuplicates writes the list of duplicates and quarantines or deletes them, after confirmation.
+func handleDuplicates(ctx context.Context, params app.CmdOptions, stats app.CmdFileStats, assumeYes bool) error {
+	if params.DuplicatesFile != "" {
+		f, err := os.Create(params.DuplicatesFile)
+		if app.IsError(err) {
+			return err
+		}
+		defer f.Close()
+
+		if err := app.WriteDuplicates(f, stats.Duplicates, params.DuplicatesFormat); app.IsError(err) {
+			return err
+		}
+		if err := f.Close(); app.IsError(err) {
+			return err
+		}
+	}
+
+	if params.DuplicatesAction == "" || len(stats.Duplicates) == 0 || params.DryRun {
+		return nil
+	}
+
+	prompt := fmt.Sprintf("Delete %d duplicates from %s?", len(stats.Duplicates), params.SrcDir)
+	if params.DuplicatesAction == app.DuplicatesActionQuarantine {
+		prompt = fmt.Sprintf("Move %d duplicates to %s?", len(stats.Duplicates), app.QuarantineDir(params))
+	}
+
+	if !assumeYes && !confirm(prompt) {
+		return nil
+	}
+
+	resolved, err := app.ResolveDuplicates(ctx, params, stats.Duplicates)
+	if !params.Quiet {
+		app.PrintLn("%d duplicates resolved (%s).", resolved, params.DuplicatesAction)
+	}
+
+	return err
+}
+
+// confirm asks a yes/no question, which is answered with no when the input is not interactive.
+func confirm(prompt string) bool {
+	if !app.IsTerminal(os.Stdin) {
+		app.PrintLn("%s Skipped, use --yes to confirm it in non-interactive runs.", prompt)
+		return false
+	}
+
+	fmt.Printf("[%s] %s [y/N] ", app.AppName, prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if app.IsError(err) {
+		fmt.Println()
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
+
+// loadCmdOptions merges the default options, the config file, the selected profile and the flags, in that order.
+// Flags only override the config when they are explicitly set.
+func loadCmdOptions(c *cli.Context) (app.CmdOptions, error) {
+	params := app.DefaultCmdOptions()
+
+	configPath := c.String("config")
+	if configPath == "" {
+		configPath = app.DefaultConfigPath()
+	}
+
+	if err := app.LoadConfig(configPath, c.String("profile"), &params); app.IsError(err) {
+		return params, err
+	}
+
+	if c.IsSet("dry-run") {
+		params.DryRun = c.Bool("dry-run")
+	}
+	if c.IsSet("limit") {
+		params.Limit = c.Uint("limit")
+	}
+	if c.IsSet("extensions") {
+		params.Extensions = c.String("extensions")
+	}
+	if c.IsSet("convert-videos") {
+		params.ConvertVideos = c.Bool("convert-videos")
+	}
+	if c.IsSet("fix-dates") {
+		params.FixDates = c.Bool("fix-dates")
+	}
+	if c.IsSet("move") {
+		params.Move = c.Bool("move")
+	}
+	if c.IsSet("mode") {
+		params.Mode = c.String("mode")
+	}
+	if params.Move {
+		// copy is the default mode, so it only conflicts with move when it is chosen explicitly
+		if params.Mode != app.ModeMove && (params.Mode != app.ModeCopy || c.IsSet("mode")) {
+			return params, fmt.Errorf("The move option cannot be combined with the %s mode.", params.Mode)
+		}
+		params.Mode = app.ModeMove
+	}
+	if c.IsSet("separate-raw") {
+		params.SeparateRaw = c.Bool("separate-raw")
+	}
+	if c.IsSet("fix-extensions") {
+		params.FixExtensions = c.Bool("fix-extensions")
+	}
+	if c.IsSet("dest-pattern") {
+		params.DestPattern = c.String("dest-pattern")
+	}
+	if c.IsSet("geocode-max-distance") {
+		params.GeocodeMaxDistance = c.Float64("geocode-max-distance")
+	}
+	if c.IsSet("gpx") {
+		params.GPXFiles = append(params.GPXFiles, c.StringSlice("gpx")...)
+	}
+	if c.IsSet("gpx-max-gap") {
+		params.GPXMaxGap = c.String("gpx-max-gap")
+	}
+	if c.IsSet("gpx-write") {
+		params.GPXWrite = c.Bool("gpx-write")
+	}
+	if c.IsSet("source-type") {
+		params.SourceType = c.String("source-type")
+	}
+	if c.IsSet("only-new") {
+		params.OnlyNew = c.Bool("only-new")
+	}
+	if c.IsSet("route") {
+		if params.Routes == nil {
+			params.Routes = map[string]string{}
+		}
+		for _, route := range c.StringSlice("route") {
+			category, dir, found := strings.Cut(route, "=")
+			if !found || dir == "" {
+				return params, fmt.Errorf("Invalid route %q, the expected format is category=directory.", route)
+			}
+			params.Routes[strings.TrimSpace(category)] = strings.TrimSpace(dir)
+		}
+	}
+	if c.IsSet("timezone") {
+		params.Timezone = c.String("timezone")
+	}
+	if c.IsSet("camera-timezone") {
+		if params.CameraTimezones == nil {
+			params.CameraTimezones = map[string]string{}
+		}
+		for _, cameraTimezone := range c.StringSlice("camera-timezone") {
+			camera, timezone, found := strings.Cut(cameraTimezone, "=")
+			if !found || timezone == "" {
+				return params, fmt.Errorf("Invalid camera timezone %q, the expected format is camera=timezone.", cameraTimezone)
+			}
+			params.CameraTimezones[strings.TrimSpace(camera)] = strings.TrimSpace(timezone)
+		}
+	}
+	if c.IsSet("trips") {
+		trips, err := app.LoadTripsFile(c.String("trips"))
+		if app.IsError(err) {
+			return params, err
+		}
+		params.Trips = append(params.Trips, trips...)
+	}
+	if c.IsSet("time-shift") {
+		for _, value := range c.StringSlice("time-shift") {
+			shift, err := app.ParseTimeShift(value)
+			if app.IsError(err) {
+				return params, err
+			}
+			params.TimeShifts = append(params.TimeShifts, shift)
+		}
+	}
+	if c.IsSet("include") {
+		params.Include = append(params.Include, c.StringSlice("include")...)
+	}
+	if c.IsSet("exclude") {
+		params.Exclude = append(params.Exclude, c.StringSlice("exclude")...)
+	}
+	if c.IsSet("min-size") {
+		size, err := app.ParseBytes(c.String("min-size"))
+		if app.IsError(err) {
+			return params, err
+		}
+		params.MinFileSize = size
+	}
+	if c.IsSet("max-size") {
+		size, err := app.ParseBytes(c.String("max-size"))
+		if app.IsError(err) {
+			return params, err
+		}
+		params.MaxFileSize = size
+	}
+	if c.IsSet("since") {
+		params.Since = c.String("since")
+	}
+	if c.IsSet("until") {
+		params.Until = c.String("until")
+	}
+	if c.IsSet("verbose") {
+		params.Verbosity = verbosity
+	}
+	if c.IsSet("log-file") {
+		params.LogFile = c.String("log-file")
+	}
+	if c.IsSet("log-format") {
+		params.LogFormat = c.String("log-format")
+	}
+	if c.IsSet("report") {
+		params.ReportFile = c.String("report")
+	}
+	if c.IsSet("report-format") {
+		params.ReportFormat = c.String("report-format")
+	}
+	if c.IsSet("duplicates") {
+		params.DuplicatesFile = c.String("duplicates")
+	}
+	if c.IsSet("duplicates-format") {
+		params.DuplicatesFormat = c.String("duplicates-format")
+	}
+	if c.IsSet("duplicates-action") {
+		params.DuplicatesAction = c.String("duplicates-action")
+	}
+	if c.IsSet("quarantine-dir") {
+		params.QuarantineDir, _ = filepath.Abs(c.String("quarantine-dir"))
+	}
+	if c.IsSet("quiet") {
+		params.Quiet = c.Bool("quiet")
+	}
+
+	track, err := app.LoadGPXFiles(params.GPXFiles)
+	if app.IsError(err) {
+		return params, err
+	}
+	params.GPXTrack = track
+
+	return params, app.ValidateCmdOptions(params)
+}
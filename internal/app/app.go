@@ -1,127 +1,253 @@
 package app
 
 import (
-	tm "github.com/buger/goterm"
+	"context"
+	"errors"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
-	"regexp"
+	"strings"
 	"time"
 )
 
 type TidyUpWalkFunc func(stats *CmdFileStats, path string, info os.FileInfo, err error) error
 
-func tidyUpFile(params CmdOptions, stats *CmdFileStats, path string, info os.FileInfo, err error) (FileMeta, error) {
+func tidyUpFile(ctx context.Context, params CmdOptions, stats *CmdFileStats, path string, info os.FileInfo, err error) (FileMeta, error) {
 	HandleError(err)
 
-	fileData, err := GetFileMetadata(params, path, info)
-	HandleError(err)
+	fileData, err := GetFileMetadata(ctx, params, path, info)
+	if isCancelled(ctx, err) {
+		return fileData, err
+	}
+	// e.g. the file was removed or renamed before being read, which can happen while watching the source directory
+	if IsError(err) {
+		stats.FailedFiles++
+		stats.Failures = append(stats.Failures, FailureRecord{Source: path, Error: err.Error()})
+		Logger.Error("file failed", "path", path, "error", err)
+		return fileData, nil
+	}
+
+	Logger.Debug("file scanned", "path", path, "size", fileData.Size, "checksum", fileData.Checksum,
+		"media_type", fileData.MediaType, "creation_time", fileData.CreationTime, "date_source", fileData.DateSource)
+
+	// Files with a media extension can still be something else, e.g. audio-only MP4 files
+	if fileData.MediaType == "" {
+		skipFile(stats, path, SkipReasonNotMedia)
+		return fileData, nil
+	}
+
+	if reason := dateSkipReason(params, fileData); reason != "" {
+		skipFile(stats, path, reason)
+		return fileData, nil
+	}
 
 	if fileData.IsAlreadyImported {
-		stats.SkippedFiles++
+		skipFile(stats, path, SkipReasonAlreadyImported)
 		return fileData, nil
 	}
 
 	if fileData.IsDuplication {
-		stats.SkippedFiles++
+		var metadataPath string
+		if fileData.DuplicateMatch == DuplicateMatchChecksum {
+			metadataPath = fileData.MetadataPath.Path
+		}
 		stats.DuplicatedFiles++
+		stats.Duplicates = append(stats.Duplicates, DuplicateRecord{
+			Source:      path,
+			DuplicateOf: fileData.DuplicateOf,
+			Match:       fileData.DuplicateMatch,
+			Metadata:    metadataPath,
+		})
+		skipFile(stats, path, SkipReasonDuplicate, "duplicate_of", fileData.DuplicateOf, "match", fileData.DuplicateMatch)
+		return fileData, nil
+	}
+
+	// A failed file is reported, but it doesn't stop the rest of the run
+	fileData, err = processFile(ctx, params, fileData)
+	if isCancelled(ctx, err) {
+		Logger.Warn("file rolled back", "path", path, "destination", fileData.Destination.Path)
+		return fileData, err
+	}
+	if IsError(err) {
+		stats.FailedFiles++
+		stats.Failures = append(stats.Failures, FailureRecord{Source: path, Error: err.Error()})
+		Logger.Error("file failed", "path", path, "destination", fileData.Destination.Path, "error", err)
 		return fileData, nil
 	}
 
-	stats.ProcessedFiles++
-	stats.TotalSize += fileData.Size
+	stats.addProcessed(fileData)
+
+	Logger.Info("file imported", "path", path, "destination", fileData.Destination.Path, "mode", fileData.ImportMode,
+		"rule", fileData.MatchedRule, "dry_run", params.DryRun)
 
-	return fileData, processFile(params, fileData)
+	return fileData, nil
 }
 
-func TidyUp(params CmdOptions) (CmdFileStats, error) {
-	return walkDir(params, func(stats *CmdFileStats, path string, info os.FileInfo, err error) error {
+// TidyUp imports the files of the source directory. When the context is cancelled, the file being imported is
+// finished or rolled back, and the stats of the run so far are returned, marked as interrupted.
+func TidyUp(ctx context.Context, params CmdOptions) (CmdFileStats, error) {
+	var progress *Progress
+	var card cardImport
+
+	params, err := withDCIMSource(params)
+	if IsError(err) {
+		return CmdFileStats{}, err
+	}
+	params.PairCache = NewPairCache()
+
+	// the progress is not shown when every file is logged
+	if params.Quiet == false && params.Verbosity == 0 {
+		total, totalBytes, err := CountFiles(ctx, params)
+		if isCancelled(ctx, err) {
+			stats := newCmdFileStats()
+			stats.Interrupted = true
+			return stats, nil
+		}
+		if IsError(err) {
+			return CmdFileStats{}, err
+		}
+		progress = NewProgress(params, total, totalBytes)
+	}
+
+	stats, err := walkDir(ctx, params, func(stats *CmdFileStats, path string, info os.FileInfo, err error) error {
 		HandleError(err)
 
-		fileMeta, err := tidyUpFile(params, stats, path, info, err)
+		failedFiles := stats.FailedFiles
+		_, err = tidyUpFile(ctx, params, stats, path, info, err)
 		if IsError(err) {
 			return err
 		}
+		card.track(path, stats.FailedFiles > failedFiles)
 
-		if params.Quiet == false {
-			printProgress(fileMeta, *stats)
+		if progress != nil {
+			progress.Update(*stats)
 		}
 
 		return nil
 	})
+
+	if progress != nil {
+		progress.Finish(stats)
+	}
+
+	if isCancelled(ctx, err) {
+		stats.Interrupted = true
+		err = nil
+	}
+
+	// an interrupted import is not remembered, since the files are not walked in the order of their numbers
+	if !IsError(err) && !stats.Interrupted && params.SourceType == SourceTypeDCIM {
+		err = card.remember(params)
+	}
+
+	return stats, err
 }
 
-func walkDir(params CmdOptions, processFileFunc TidyUpWalkFunc) (CmdFileStats, error) {
-	stats := CmdFileStats{}
-	return stats, filepath.Walk(params.SrcDir, func(path string, info os.FileInfo, err error) error {
+// walkDir calls the process function for every file of the source directory that is not filtered out, until the
+// context is cancelled.
+func walkDir(ctx context.Context, params CmdOptions, processFileFunc TidyUpWalkFunc) (CmdFileStats, error) {
+	stats := newCmdFileStats()
+
+	params, err := withDCIMSource(params)
+	if IsError(err) {
+		return stats, err
+	}
+	filter := newFileFilter(params)
+	params.PairCache.useFilter(filter)
+
+	err = filepath.Walk(params.SrcDir, func(path string, info os.FileInfo, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if IsError(err) {
 			return err
 		}
 
-		if regexp.MustCompile(RegexExcludeDirs).MatchString(path) {
-			if info.IsDir() {
+		if info.IsDir() {
+			if reason := filter.dirSkipReason(path); reason != "" {
+				Logger.Info("directory skipped", "path", path, "reason", reason)
 				return filepath.SkipDir
 			}
-			return nil
+			return filter.loadIgnoreFile(path)
 		}
 
-		if info.IsDir() {
-			return nil
-		}
+		stats.ScannedSize += info.Size()
 
-		if !regexp.MustCompile(RegexImage).MatchString(path) &&
-			!regexp.MustCompile(RegexVideo).MatchString(path) {
-			stats.SkippedFiles++
+		if reason := filter.fileSkipReason(path, info); reason != "" {
+			skipFile(&stats, path, reason)
 			return nil
 		}
 
-		fsize := info.Size()
+		return processFileFunc(&stats, path, info, err)
+	})
 
-		// File is too small?
-		if fsize < int64(MinFileSize) {
-			stats.SkippedFiles++
-			return nil
-		}
+	return stats, err
+}
 
-		// File extension is in allowed list?
-		if params.Extensions != "" && !regexp.MustCompile("(?i)\\.("+params.Extensions+")$").MatchString(path) {
-			stats.SkippedFiles++
-			return nil
-		}
+// isCancelled tells if an error was caused by the cancellation of the context, e.g. by an interrupt signal.
+func isCancelled(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err())
+}
 
-		return processFileFunc(&stats, path, info, err)
-	})
+func skipFile(stats *CmdFileStats, path string, reason string, attrs ...any) {
+	stats.SkippedFiles++
+	stats.SkipReasons[reason]++
+
+	Logger.Info("file skipped", append([]any{"path", path, "reason", reason}, attrs...)...)
 }
 
-func processFile(params CmdOptions, file FileMeta) error {
+// processFile puts the file in the destination and writes its metadata. If the context is cancelled while the file
+// is being transferred, the incomplete destination file is removed, otherwise the file is finished.
+func processFile(ctx context.Context, params CmdOptions, file FileMeta) (FileMeta, error) {
 	destDir := params.DestDir + "/" + file.Destination.Dirname
 	destFile := destDir + "/" + file.Destination.Basename + file.Destination.Extension
 
 	destFileMeta := file.MetadataPath.Path
 	destDirMeta := path.Dir(destFileMeta)
 
+	file.ImportMode = params.Mode
+
 	if params.DryRun {
-		return nil
+		return file, nil
 	}
 
-	MakeDirIfNotExists(destDirMeta)
-	MakeDirIfNotExists(destDir)
+	for _, dir := range []string{destDirMeta, destDir} {
+		if err := os.MkdirAll(dir, DirPerms); IsError(err) {
+			return file, err
+		}
+	}
 
 	// TODO: convert videos
 
-	if params.Move {
-		HandleError(FileMove(file.Source.Path, destFile))
-	} else {
-		HandleError(FileCopy(file.Source.Path, destFile, true))
+	var err error
+	file.ImportMode, err = TransferFile(ctx, file.Source.Path, destFile, params.Mode)
+	if IsError(err) {
+		return file, err
 	}
 
-	if params.FixDates {
-		ct, err := ParseDateWithTimezone(time.RFC3339, file.CreationTime, file.GPS.Timezone)
-		mt, err2 := ParseDateWithTimezone(time.RFC3339, file.ModificationTime, file.GPS.Timezone)
+	// Linked files cannot be modified, it would modify the source files too
+	if IsLinkMode(file.ImportMode) {
+		if params.GPXWrite || params.FixDates {
+			Logger.Warn("the GPS tags and dates of linked files are not modified", "path", destFile, "mode", file.ImportMode)
+		}
+	} else {
+		// Write the GPS tags before fixing the dates, since exiftool changes the modification date
+		if params.GPXWrite && strings.HasPrefix(file.GPS.PositionSource, GPSSourceGPX) {
+			if err := WriteGPSTags(destFile, file.GPS); IsError(err) {
+				return file, err
+			}
+		}
 
-		if !IsError(err) && !IsError(err2) {
-			HandleError(FileFixDates(destFile, ct, mt))
+		if params.FixDates {
+			ct, err := ParseDateWithTimezone(time.RFC3339, file.CreationTime, file.GPS.Timezone)
+			mt, err2 := ParseDateWithTimezone(time.RFC3339, file.ModificationTime, file.GPS.Timezone)
+
+			if !IsError(err) && !IsError(err2) {
+				if err := FileFixDates(destFile, ct, mt); IsError(err) {
+					return file, err
+				}
+			}
 		}
 	}
 
@@ -129,25 +255,13 @@ func processFile(params CmdOptions, file FileMeta) error {
 	if !PathExists(destFileMeta) {
 		meta, err := JsonEncodePretty(file)
 		if IsError(err) {
-			return err
+			return file, err
 		}
 		err = ioutil.WriteFile(destFileMeta, meta, FilePerms)
 		if IsError(err) {
-			return err
+			return file, err
 		}
 	}
 
-	return nil
-}
-
-func printProgress(currentFile FileMeta, stats CmdFileStats) {
-	PrintReplaceLn(
-		"[%s] "+tm.Color(tm.Bold("Stats: %s duplicates / %s skipped / %s processed / %s total size"), tm.YELLOW)+" / file: %s",
-		AppName,
-		ToString(stats.DuplicatedFiles),
-		ToString(stats.SkippedFiles),
-		ToString(stats.ProcessedFiles),
-		TotalBytesToString(stats.TotalSize, false),
-		currentFile.Source.Path,
-	)
+	return file, nil
 }
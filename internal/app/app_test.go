@@ -0,0 +1,34 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTidyUpFileRemovedBeforeReading(t *testing.T) {
+	params := DefaultCmdOptions()
+	params.SrcDir, params.DestDir = t.TempDir(), t.TempDir()
+	params.PairCache = NewPairCache()
+
+	path := filepath.Join(params.SrcDir, "IMG_0001.JPG")
+	if err := os.WriteFile(path, []byte("image"), FilePerms); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := newCmdFileStats()
+	if _, err := tidyUpFile(context.Background(), params, &stats, path, info, nil); err != nil {
+		t.Fatalf("tidyUpFile() error = %v", err)
+	}
+	if stats.FailedFiles != 1 || len(stats.Failures) != 1 || stats.Failures[0].Source != path {
+		t.Errorf("tidyUpFile() failures = %d %v, want the removed file", stats.FailedFiles, stats.Failures)
+	}
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// knownScreenSizes are the native resolutions (in portrait) of common phones, tablets and monitors,
+// used to detect the PNG screenshots that don't have any hint in their name.
+var knownScreenSizes = [][2]int{
+	// iPhone
+	{640, 1136}, {750, 1334}, {1080, 1920}, {1242, 2208}, {1125, 2436}, {828, 1792}, {1242, 2688},
+	{1170, 2532}, {1284, 2778}, {1080, 2340}, {1179, 2556}, {1290, 2796}, {1206, 2622}, {1320, 2868},
+	// iPad
+	{768, 1024}, {1536, 2048}, {1620, 2160}, {1640, 2360}, {1668, 2224}, {1668, 2388}, {2048, 2732},
+	// Android
+	{720, 1280}, {720, 1600}, {1080, 2400}, {1080, 2280}, {1080, 2160}, {1440, 2560}, {1440, 2960},
+	{1440, 3040}, {1440, 3088}, {1440, 3200}, {1344, 2992}, {1280, 2856},
+	// Monitors and laptops
+	{768, 1366}, {900, 1440}, {900, 1600}, {1050, 1680}, {1200, 1920}, {1600, 2560},
+	{2160, 3840}, {1964, 3024}, {2234, 3456}, {1664, 2560}, {1800, 2880},
+}
+
+// isScreenShot tells if the file is a screenshot or a screen recording, by the default name given by the OS to
+// them, e.g. "Screenshot_20200601-081500.png", "Captura de pantalla 2021-03-07 a las 9.05.31.png" or
+// "RPReplay_Final1612345678.mp4".
+func isScreenShot(params CmdOptions, file FileMeta) bool {
+	if regexp.MustCompile(params.ScreenShots).MatchString(filepath.Base(file.Source.Path)) {
+		return true
+	}
+
+	// PNG images without camera info and the exact size of a screen are most likely screenshots. Other formats
+	// are not checked, since many photos and video frames are resized to sizes like 1920x1080.
+	return strings.EqualFold(file.DetectedExtension, ".png") && file.CameraModel == "" &&
+		isScreenSize(file.Exif.Data.ImageWidth, file.Exif.Data.ImageHeight)
+}
+
+func isScreenSize(width string, height string) bool {
+	w, err := strconv.ParseFloat(width, 64)
+	if IsError(err) {
+		return false
+	}
+	h, err := strconv.ParseFloat(height, 64)
+	if IsError(err) {
+		return false
+	}
+
+	for _, size := range knownScreenSizes {
+		if (int(w) == size[0] && int(h) == size[1]) || (int(w) == size[1] && int(h) == size[0]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// detectCategory classifies the file as a messaging app download, a screenshot or screen recording
+// or an edited export, so it can be routed to its own directory. Messaging downloads are checked first, since
+// they are often resized to the size of a screen.
+func detectCategory(params CmdOptions, file FileMeta) string {
+	searchStr := file.Source.Path + ":" + file.Exif.Data.CreatorTool + ":" + file.Exif.Data.Software
+
+	if regexp.MustCompile(params.Messaging).MatchString(searchStr) {
+		return CategoryMessaging
+	}
+
+	if file.IsScreenShot {
+		return CategoryScreenShot
+	}
+
+	if regexp.MustCompile(params.Edited).MatchString(searchStr) {
+		return CategoryEdited
+	}
+
+	return ""
+}
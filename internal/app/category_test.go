@@ -0,0 +1,58 @@
+package app
+
+import "testing"
+
+func TestDetectCategory(t *testing.T) {
+	params := DefaultCmdOptions()
+	image := func(path string, ext string, width string, height string, camera string) FileMeta {
+		return FileMeta{
+			Source:            FilePathInfo{Path: path, Extension: ext},
+			MediaType:         MediaTypeImage,
+			DetectedExtension: ext,
+			CameraModel:       camera,
+			Exif:              ExifData{Data: ExifToolData{ImageWidth: width, ImageHeight: height}},
+		}
+	}
+
+	video := func(path string) FileMeta {
+		return FileMeta{Source: FilePathInfo{Path: path, Extension: ".mp4"}, MediaType: MediaTypeVideo}
+	}
+	captureOne := image("/src/IMG_0005.jpg", ".jpg", "4032", "3024", "Canon EOS R6")
+	captureOne.Exif.Data.CreatorTool = "Capture One 22 Macintosh"
+
+	tests := []struct {
+		name string
+		file FileMeta
+		want string
+	}{
+		{name: "photo", file: image("/src/IMG_0001.jpg", ".jpg", "4032", "3024", "iPhone 12"), want: ""},
+		{name: "named screenshot", file: image("/src/Screenshot 2021-03-07 at 9.05.31.png", ".png", "100", "100", ""), want: CategoryScreenShot},
+		{name: "png of a screen size", file: image("/src/IMG_0002.png", ".png", "1170", "2532", ""), want: CategoryScreenShot},
+		{name: "landscape png of a screen size", file: image("/src/IMG_0002.png", ".png", "1920", "1080", ""), want: CategoryScreenShot},
+		{name: "png of a screen size with camera", file: image("/src/IMG_0002.png", ".png", "1170", "2532", "iPhone 12"), want: ""},
+		{name: "jpeg of a screen size", file: image("/src/wallpaper.jpg", ".jpg", "1920", "1080", ""), want: ""},
+		{name: "whatsapp download of a screen size", file: image("/src/IMG-20190514-WA0012.jpg", ".jpg", "1080", "1920", ""), want: CategoryMessaging},
+		{name: "whatsapp screenshot", file: image("/src/WhatsApp/Screenshot_20200601.png", ".png", "1080", "2400", ""), want: CategoryMessaging},
+		{name: "edited export", file: image("/src/IMG_0003-edited.jpg", ".jpg", "4032", "3024", ""), want: CategoryEdited},
+		{name: "capture one export", file: captureOne, want: CategoryEdited},
+		{name: "captured in the name", file: image("/src/Captured moments.jpg", ".jpg", "4032", "3024", "Canon EOS R6"), want: ""},
+		{name: "screenshots directory", file: image("/src/Screenshots/IMG_0004.jpg", ".jpg", "4032", "3024", "iPhone 12"), want: ""},
+		{name: "android screenshot", file: image("/src/Screenshot_20200601-081500.png", ".png", "1", "1", ""), want: CategoryScreenShot},
+		{name: "spanish screenshot", file: image("/src/Captura de pantalla 2021-03-07 a las 9.05.31.png", ".png", "1", "1", ""), want: CategoryScreenShot},
+		{name: "portuguese screenshot", file: image("/src/Captura de Tela 2021-03-07 às 9.05.31.png", ".png", "1", "1", ""), want: CategoryScreenShot},
+		{name: "french screenshot", file: image("/src/Capture d\u2019écran 2021-03-07 à 9.05.31.png", ".png", "1", "1", ""), want: CategoryScreenShot},
+		{name: "german screenshot", file: image("/src/Bildschirmfoto 2021-03-07 um 9.05.31.png", ".png", "1", "1", ""), want: CategoryScreenShot},
+		{name: "macos recording", file: video("/src/Screen Recording 2022-01-02 at 10.11.12.mov"), want: CategoryScreenShot},
+		{name: "ios recording", file: video("/src/RPReplay_Final1612345678.MP4"), want: CategoryScreenShot},
+		{name: "android recording", file: video("/src/Screen_Recording_20200101-101010.mp4"), want: CategoryScreenShot},
+		{name: "android screen recorder", file: video("/src/screen-20200101-101010.mp4"), want: CategoryScreenShot},
+		{name: "video", file: video("/src/VID_20200101_101010.mp4"), want: ""},
+	}
+
+	for _, tt := range tests {
+		tt.file.IsScreenShot = isScreenShot(params, tt.file)
+		if got := detectCategory(params, tt.file); got != tt.want {
+			t.Errorf("%s: detectCategory() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFile is the structure of the YAML configuration file. The top level options are applied first,
+// then the ones of the selected profile on top of them.
+type ConfigFile struct {
+	CmdOptions `yaml:",inline"`
+	Profiles   map[string]yaml.Node `yaml:"profiles"`
+}
+
+func DefaultCmdOptions() CmdOptions {
+	return CmdOptions{
+		Timezone:           DefaultTimezone,
+		MinFileSize:        MinFileSize,
+		ExcludeDirs:        RegexExcludeDirs,
+		ScreenShots:        RegexScreenShot,
+		Messaging:          RegexMessaging,
+		Edited:             RegexEdited,
+		DirMetadata:        DirMetadata,
+		DirImages:          DirImages,
+		DirImagesRaw:       DirImagesRaw,
+		DirVideos:          DirVideos,
+		DestPattern:        DefaultDestPattern,
+		GeocodeMaxDistance: DefaultGeocodeMaxDistance,
+		GPXMaxGap:          DefaultGPXMaxGap,
+		LogFormat:          LogFormatText,
+		ReportFormat:       ReportFormatText,
+		DuplicatesFormat:   DuplicatesFormatCSV,
+		Mode:               ModeCopy,
+		SourceType:         SourceTypeDir,
+		FilenameDates:      DefaultFilenameDatePatterns,
+		DateSources:        DefaultDateSources,
+	}
+}
+
+// DefaultConfigPath returns the first existing config.yaml or config.yml file under ~/.config/mediatidy
+// (or $XDG_CONFIG_HOME/mediatidy), or an empty string if there is none.
+func DefaultConfigPath() string {
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		homeDir, err := os.UserHomeDir()
+		if IsError(err) {
+			return ""
+		}
+		configDir = filepath.Join(homeDir, ".config")
+	}
+
+	for _, name := range []string{"config.yaml", "config.yml"} {
+		path := filepath.Join(configDir, AppName, name)
+		if PathExists(path) {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// LoadConfig reads the config file and applies its options and the ones of the given profile (if any) to params.
+func LoadConfig(path string, profile string, params *CmdOptions) error {
+	if path == "" {
+		if profile != "" {
+			return fmt.Errorf("Profile %q cannot be used without a config file.", profile)
+		}
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if IsError(err) {
+		return err
+	}
+
+	config := ConfigFile{CmdOptions: *params}
+	if err = yaml.Unmarshal(data, &config); IsError(err) {
+		return fmt.Errorf("Cannot parse config file %s: %s", path, err)
+	}
+
+	if profile != "" {
+		profileNode, ok := config.Profiles[profile]
+		if !ok {
+			return fmt.Errorf("Profile %q not found in config file %s.", profile, path)
+		}
+		if err = profileNode.Decode(&config.CmdOptions); IsError(err) {
+			return fmt.Errorf("Cannot parse profile %q: %s", profile, err)
+		}
+	}
+
+	// like the --quarantine-dir flag, so it can be compared with the source directory
+	if config.QuarantineDir != "" {
+		config.QuarantineDir, _ = filepath.Abs(config.QuarantineDir)
+	}
+
+	*params = config.CmdOptions
+
+	return ValidateCmdOptions(*params)
+}
+
+func ValidateCmdOptions(params CmdOptions) error {
+	if _, err := regexp.Compile(params.ExcludeDirs); IsError(err) {
+		return fmt.Errorf("Invalid exclude_dirs regex: %s", err)
+	}
+
+	if _, err := regexp.Compile(params.ScreenShots); IsError(err) {
+		return fmt.Errorf("Invalid screenshots regex: %s", err)
+	}
+
+	if _, err := regexp.Compile(params.Messaging); IsError(err) {
+		return fmt.Errorf("Invalid messaging regex: %s", err)
+	}
+
+	if _, err := regexp.Compile(params.Edited); IsError(err) {
+		return fmt.Errorf("Invalid edited regex: %s", err)
+	}
+
+	if err := validatePathPattern("destination pattern", params.DestPattern); IsError(err) {
+		return err
+	}
+
+	if err := validateLogFormat(params.LogFormat); IsError(err) {
+		return err
+	}
+
+	if err := validateMode(params.Mode); IsError(err) {
+		return err
+	}
+
+	if err := validateReportFormat(params.ReportFormat); IsError(err) {
+		return err
+	}
+
+	if err := validateDuplicatesOptions(params); IsError(err) {
+		return err
+	}
+
+	if err := validateGPXOptions(params); IsError(err) {
+		return err
+	}
+
+	if err := validateSourceType(params); IsError(err) {
+		return err
+	}
+
+	if err := validateFilenameDatePatterns(params.FilenameDates); IsError(err) {
+		return err
+	}
+
+	if err := validateDateSources(params.DateSources); IsError(err) {
+		return err
+	}
+
+	if err := validateTimeShifts(params.TimeShifts); IsError(err) {
+		return err
+	}
+
+	if err := validateRoutingRules(params.Rules); IsError(err) {
+		return err
+	}
+
+	for category := range params.Routes {
+		if category != CategoryScreenShot && category != CategoryMessaging && category != CategoryEdited {
+			return fmt.Errorf("Unknown route category %q, it must be one of: %s, %s, %s.",
+				category, CategoryScreenShot, CategoryMessaging, CategoryEdited)
+		}
+	}
+
+	if err := validateTimezones(params); IsError(err) {
+		return err
+	}
+
+	for _, date := range []string{params.Since, params.Until} {
+		if _, err := time.Parse(FilterDateFormat, date); date != "" && IsError(err) {
+			return fmt.Errorf("Invalid date %q, the expected format is YYYY-MM-DD.", date)
+		}
+	}
+
+	if params.MaxFileSize > 0 && params.MaxFileSize < params.MinFileSize {
+		return errors.New("The maximum file size cannot be lower than the minimum file size.")
+	}
+
+	if params.DirMetadata == "" || params.DirImages == "" || params.DirImagesRaw == "" || params.DirVideos == "" {
+		return errors.New("Destination directory names cannot be empty.")
+	}
+
+	return nil
+}
+
+func ConfigToYaml(params CmdOptions) ([]byte, error) {
+	return yaml.Marshal(params)
+}
@@ -0,0 +1,27 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigQuarantineDir(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte("quarantine_dir: dupes\n"), FilePerms); err != nil {
+		t.Fatal(err)
+	}
+
+	params := DefaultCmdOptions()
+	if err := LoadConfig(configPath, "", &params); err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(workDir, "dupes"); params.QuarantineDir != want {
+		t.Errorf("LoadConfig() quarantine dir = %q, want %q", params.QuarantineDir, want)
+	}
+}
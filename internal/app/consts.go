@@ -8,29 +8,55 @@ import (
 const (
 	AppName = "mediatidy"
 
+	ExitCodeInterrupted = 130 // like shells do for SIGINT
+
 	IsUnix = runtime.GOOS == "linux" || runtime.GOOS == "darwin" || runtime.GOOS == "freebsd" || runtime.GOOS == "openbsd"
 
 	MinFileSize = 1000 // 1000 B / 1 KB
 	DirPerms    = 0755
 	FilePerms   = 0644
 
+	IgnoreFileName = ".mediatidyignore"
+
+	DefaultDestPattern        = "{media}/{year}/{month}"
+	DefaultGeocodeMaxDistance = 100 // km
+	DefaultGPXMaxGap          = "30m"
+
 	DirMetadata        = ".metadata"
+	DirQuarantine      = ".quarantine"
+	DirTrash           = ".trash"
+	DirState           = ".mediatidy" // state of the destination, like the imported cards
 	DirVideos          = "originals"
 	DirImages          = "originals"
+	DirImagesRaw       = "raw"
 	DirVideosConverted = "converted"
 
 	MediaTypeVideo = "video"
 	MediaTypeImage = "image"
 
+	CategoryScreenShot = "screenshot"
+	CategoryMessaging  = "messaging"
+	CategoryEdited     = "edited"
+
 	DateFormat          = time.RFC3339
 	DateTimestampFormat = "2006:01:02 15:04:05"
+	FilterDateFormat    = "2006-01-02"
 	DefaultTimezone     = "Europe/Berlin"
 
 	DefaultCameraModelFallback = "Unknown"
 
-	RegexImage       = "(?i)\\.(jpg|jpeg|gif|png|heic|heif|webp|tiff|tif|bmp|raw|svg|psd|ai)$"
+	RegexImage       = "(?i)\\.(jpg|jpeg|gif|png|heic|heif|avif|webp|tiff|tif|bmp|svg|psd|ai|" + rawExtensions + ")$"
+	RegexImageRaw    = "(?i)\\.(" + rawExtensions + ")$"
+	RegexImagePaired = "(?i)\\.(jpg|jpeg|heic|heif)$"
 	RegexVideo       = "(?i)\\.(mpg|wmv|avi|mov|m4v|3gp|mp4|flv|webm|ogv|ts|divx|mkv|mpeg)$"
 	RegexVideoOld    = "(?i)\\.(mpg|wmv|avi|mov|m4v|3gp|flv|divx|mpeg)$"
 	RegexExcludeDirs = "(?i)(\\.([a-z_0-9-]+)|/bower_components|/node_modules|/vendor|/Developer)/.*$"
-	RegexScreenShot  = "(?i)(Screen Shot|Screen Record|Screenshot|Captur)"
+	RegexScreenShot  = "(?i)^(Screen[ _-]?(Shot|Record)|RPReplay|screen-[0-9]|Captura de (pantalla|tela)|Capture d.[ée]cran|Bildschirmfoto)"
+	RegexMessaging   = "(?i)(WhatsApp|Telegram|Signal|Messenger|Viber|WeChat|-WA[0-9]{4}\\.)"
+	RegexEdited      = "(?i)(Photoshop|Lightroom|Capture One|Snapseed|GIMP|VSCO|Pixelmator|Affinity|Luminar|-edited|_edit)"
+
+	// Camera RAW formats: Canon, Nikon, Sony, Fujifilm, Olympus, Panasonic, Pentax, Samsung, Leica, Hasselblad,
+	// Phase One, Sigma, Kodak, Minolta, Mamiya, Epson and Adobe's DNG.
+	rawExtensions = "raw|3fr|ari|arw|bay|cap|cr2|cr3|crw|dcr|dcs|dng|drf|eip|erf|fff|iiq|k25|kdc|mdc|mef|mos|mrw|" +
+		"nef|nrw|orf|pef|ptx|pxn|raf|rw2|rwl|rwz|sr2|srf|srw|x3f"
 )
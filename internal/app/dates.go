@@ -0,0 +1,164 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	DateConfidenceHigh   = "high"
+	DateConfidenceMedium = "medium"
+	DateConfidenceLow    = "low"
+)
+
+// DefaultDateSources is the default ranking of the creation date sources, from the most to the least reliable:
+// the original capture date of the camera, the QuickTime creation dates (which are in UTC), the GPS time,
+// a date in the file name, and the filesystem times.
+var DefaultDateSources = []string{
+	"DateTimeOriginal",
+	"CreationDate",
+	"CreateDate",
+	"DateTimeDigitized",
+	"MediaCreateDate",
+	"TrackCreateDate",
+	"GPSDateTime",
+	"FileName",
+	"FileModifyDate",
+	"ModificationTime",
+}
+
+// dateCandidate is a possible creation date of a file, coming from one of its metadata tags or other sources.
+type dateCandidate struct {
+	Source     string
+	Value      string
+	Offset     string    // timezone offset of Value, like "+02:00", if it is reported separately
+	IsUTC      bool      // Value is in UTC instead of the local time of the camera
+	Parsed     time.Time // already parsed date, for sources that are not a metadata tag
+	Confidence string
+}
+
+// dateCandidates returns the possible creation dates of a file by their source name.
+func dateCandidates(params CmdOptions, data FileMeta, loc *time.Location) map[string]dateCandidate {
+	exif := data.Exif.Data
+	isVideo := data.MediaType == MediaTypeVideo
+
+	candidates := map[string]dateCandidate{
+		"DateTimeOriginal":  {Value: exif.DateTimeOriginal, Offset: exif.OffsetTimeOriginal, Confidence: DateConfidenceHigh},
+		"CreationDate":      {Value: exif.CreationDate, Confidence: DateConfidenceHigh},
+		"CreateDate":        {Value: exif.CreateDate, Offset: exif.OffsetTimeDigitized, IsUTC: isVideo, Confidence: DateConfidenceHigh},
+		"DateTimeDigitized": {Value: exif.DateTimeDigitized, Offset: exif.OffsetTimeDigitized, Confidence: DateConfidenceMedium},
+		"MediaCreateDate":   {Value: exif.MediaCreateDate, IsUTC: true, Confidence: DateConfidenceMedium},
+		"TrackCreateDate":   {Value: exif.TrackCreateDate, IsUTC: true, Confidence: DateConfidenceMedium},
+		"GPSDateTime":       {Value: exif.GPSDateTime, IsUTC: true, Confidence: DateConfidenceMedium},
+		"FileModifyDate":    {Value: exif.FileModifyDate, Confidence: DateConfidenceLow},
+		"ModificationTime":  {Value: data.ModificationTime, Confidence: DateConfidenceLow},
+	}
+
+	if t, patternName, ok := parseFilenameDate(params.FilenameDates, filepath.Base(data.Source.Path), loc); ok {
+		candidates["FileName"] = dateCandidate{Source: "FileName:" + patternName, Parsed: t, Confidence: DateConfidenceLow}
+	}
+
+	for name, candidate := range candidates {
+		if candidate.Source == "" {
+			candidate.Source = name
+			candidates[name] = candidate
+		}
+	}
+
+	return candidates
+}
+
+// resolveCreationDate picks the first valid date of the candidates, in the order of the configured date sources.
+// Camera local times without offset are interpreted in the timezone of the file.
+// It returns the date, the name of its source and the confidence.
+func resolveCreationDate(params CmdOptions, data FileMeta) (time.Time, string, string) {
+	loc, err := LoadTimezone(data.GPS.Timezone)
+	if IsError(err) {
+		loc = time.UTC
+	}
+
+	now := params.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	sources := params.DateSources
+	if len(sources) == 0 {
+		sources = DefaultDateSources
+	}
+
+	candidates := dateCandidates(params, data, loc)
+	for _, source := range sources {
+		candidate, ok := candidates[source]
+		if !ok {
+			continue
+		}
+		t, ok := parseCandidateDate(candidate, loc)
+		if !ok || t.Year() <= 1970 || t.After(now.Add(24*time.Hour)) {
+			continue
+		}
+
+		return t, candidate.Source, candidate.Confidence
+	}
+
+	t, _ := time.Parse(DateFormat, data.ModificationTime)
+
+	return t, "ModificationTime", DateConfidenceLow
+}
+
+func parseCandidateDate(candidate dateCandidate, loc *time.Location) (time.Time, bool) {
+	if !candidate.Parsed.IsZero() {
+		return candidate.Parsed, true
+	}
+
+	value := strings.TrimSpace(candidate.Value)
+	if value == "" || strings.HasPrefix(value, "0000") {
+		return time.Time{}, false
+	}
+
+	if candidate.Offset != "" && !regexp.MustCompile(`(Z|[+-][0-9]{2}:[0-9]{2})$`).MatchString(value) {
+		value += candidate.Offset
+	}
+
+	if candidate.IsUTC {
+		loc = time.UTC
+	}
+
+	return ParseExifDate(value, loc)
+}
+
+// ParseExifDate parses the date formats reported by exiftool (e.g. "2019:05:14 10:22:33", with optional
+// sub-seconds and timezone offset) and RFC3339 dates. Dates without offset are interpreted in the given location.
+func ParseExifDate(value string, loc *time.Location) (time.Time, bool) {
+	layoutsWithOffset := []string{DateFormat, DateTimestampFormat + "Z07:00", DateTimestampFormat + ".999999999Z07:00"}
+	for _, layout := range layoutsWithOffset {
+		if t, err := time.Parse(layout, value); !IsError(err) {
+			return t, true
+		}
+	}
+
+	for _, layout := range []string{DateTimestampFormat, DateTimestampFormat + ".999999999"} {
+		if t, err := time.ParseInLocation(layout, value, loc); !IsError(err) {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func validateDateSources(sources []string) error {
+	for _, source := range sources {
+		known := false
+		for _, defaultSource := range DefaultDateSources {
+			known = known || source == defaultSource
+		}
+		if !known {
+			return fmt.Errorf("Unknown date source %q, it must be one of: %s", source, strings.Join(DefaultDateSources, ", "))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseExifDate(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Skip("timezone database not available")
+	}
+
+	tests := []struct {
+		value  string
+		want   time.Time
+		wantOK bool
+	}{
+		{value: ""},
+		{value: "garbage"},
+		{value: "0000:00:00 00:00:00"},
+		{value: "2019:05:14"},
+		{value: "2019:02:31 10:22:33"},
+		{value: "2019:05:14 10:22:33", want: time.Date(2019, 5, 14, 10, 22, 33, 0, madrid), wantOK: true},
+		{value: "2019:05:14 10:22:33.45", want: time.Date(2019, 5, 14, 10, 22, 33, 450000000, madrid), wantOK: true},
+		{value: "2019:05:14 10:22:33+05:30", want: time.Date(2019, 5, 14, 4, 52, 33, 0, time.UTC), wantOK: true},
+		{value: "2019:05:14 10:22:33Z", want: time.Date(2019, 5, 14, 10, 22, 33, 0, time.UTC), wantOK: true},
+		{value: "2019:05:14 10:22:33.123-07:00", want: time.Date(2019, 5, 14, 17, 22, 33, 123000000, time.UTC), wantOK: true},
+		{value: "2019-05-14T10:22:33+02:00", want: time.Date(2019, 5, 14, 8, 22, 33, 0, time.UTC), wantOK: true},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseExifDate(tt.value, madrid)
+		if ok != tt.wantOK || !got.Equal(tt.want) {
+			t.Errorf("ParseExifDate(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestParseCandidateDate(t *testing.T) {
+	parsed := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		candidate dateCandidate
+		want      time.Time
+		wantOK    bool
+	}{
+		{name: "empty", candidate: dateCandidate{}},
+		{name: "blank", candidate: dateCandidate{Value: "   "}},
+		{name: "zero date", candidate: dateCandidate{Value: "0000:00:00 00:00:00"}},
+		{name: "garbage", candidate: dateCandidate{Value: "not a date"}},
+		{name: "already parsed", candidate: dateCandidate{Parsed: parsed}, want: parsed, wantOK: true},
+		{
+			name:      "local time",
+			candidate: dateCandidate{Value: "2019:05:14 10:22:33"},
+			want:      time.Date(2019, 5, 14, 10, 22, 33, 0, time.FixedZone("", 2*3600)),
+			wantOK:    true,
+		},
+		{
+			name:      "separate offset",
+			candidate: dateCandidate{Value: "2019:05:14 10:22:33", Offset: "-03:00"},
+			want:      time.Date(2019, 5, 14, 13, 22, 33, 0, time.UTC),
+			wantOK:    true,
+		},
+		{
+			name:      "offset already in the value",
+			candidate: dateCandidate{Value: "2019:05:14 10:22:33+01:00", Offset: "-03:00"},
+			want:      time.Date(2019, 5, 14, 9, 22, 33, 0, time.UTC),
+			wantOK:    true,
+		},
+		{
+			name:      "utc",
+			candidate: dateCandidate{Value: "2019:05:14 10:22:33", IsUTC: true},
+			want:      time.Date(2019, 5, 14, 10, 22, 33, 0, time.UTC),
+			wantOK:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseCandidateDate(tt.candidate, time.FixedZone("", 2*3600))
+		if ok != tt.wantOK || !got.Equal(tt.want) {
+			t.Errorf("%s: parseCandidateDate() = %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
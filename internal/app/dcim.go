@@ -0,0 +1,252 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SourceTypeDir  = "dir"
+	SourceTypeDCIM = "dcim" // memory card or phone, with the DCF layout, e.g. DCIM/100CANON/IMG_0001.JPG
+
+	DirDCIM   = "DCIM"
+	CardsFile = "cards.json" // in the state directory of the destination
+)
+
+var SourceTypes = []string{SourceTypeDir, SourceTypeDCIM}
+
+var (
+	// DCF directories have a number from 100 to 999 and 5 free characters, e.g. 100CANON or 101_PANA
+	regexDCFDir = regexp.MustCompile(`(?i)^([1-9]\d{2})[0-9A-Z_]{5}$`)
+	// DCF files have 4 free characters and a number from 0001 to 9999, e.g. IMG_0001.JPG or DSC00042.ARW
+	regexDCFFile = regexp.MustCompile(`(?i)^[0-9A-Z_]{4}(\d{4})\.[0-9A-Z]+$`)
+)
+
+// VolumeInfo identifies the memory card or disk a file was imported from.
+type VolumeInfo struct {
+	Label string
+	UUID  string // the volume serial number on FAT and exFAT cards, e.g. "3A1F-09C2"
+}
+
+// Key returns the identifier of the volume to remember it, or an empty string if it's unknown.
+func (v VolumeInfo) Key() string {
+	if v.UUID != "" {
+		return v.UUID
+	}
+
+	return v.Label
+}
+
+// ImportedCard is what is remembered from a memory card after importing it.
+type ImportedCard struct {
+	Volume     VolumeInfo
+	LastNumber int       // highest DCF number of the imported files, see DCFNumber
+	LastImport time.Time // end of the last import
+}
+
+// DCIMSource is the memory card or phone being imported with the dcim source type.
+type DCIMSource struct {
+	Dir    string // the DCIM directory
+	Volume VolumeInfo
+	Card   ImportedCard // the previous import of the card, if any
+}
+
+// DCFNumber returns the number of a file in the DCF layout, combining its directory and file numbers, since the
+// file numbers can restart in every directory, e.g. 1010042 for 101CANON/IMG_0042.JPG. It returns 0 for other files.
+func DCFNumber(path string) int {
+	dirMatch := regexDCFDir.FindStringSubmatch(filepath.Base(filepath.Dir(path)))
+	fileMatch := regexDCFFile.FindStringSubmatch(filepath.Base(path))
+	if dirMatch == nil || fileMatch == nil {
+		return 0
+	}
+
+	dirNumber, _ := strconv.Atoi(dirMatch[1])
+	fileNumber, _ := strconv.Atoi(fileMatch[1])
+
+	return dirNumber*10000 + fileNumber
+}
+
+// findDCIMDir returns the DCIM directory of a mounted card or phone, which can also be the source directory itself.
+func findDCIMDir(srcDir string) (string, error) {
+	if strings.EqualFold(filepath.Base(srcDir), DirDCIM) {
+		return srcDir, nil
+	}
+
+	entries, err := os.ReadDir(srcDir)
+	if IsError(err) {
+		return "", err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && strings.EqualFold(entry.Name(), DirDCIM) {
+			return filepath.Join(srcDir, entry.Name()), nil
+		}
+	}
+
+	return "", fmt.Errorf("No %s directory found in %s.", DirDCIM, srcDir)
+}
+
+// NewDCIMSource finds the DCIM directory and the volume of the source directory, and what was imported from it
+// before, according to the cards file of the destination.
+func NewDCIMSource(params CmdOptions) (DCIMSource, error) {
+	dir, err := findDCIMDir(params.SrcDir)
+	if IsError(err) {
+		return DCIMSource{}, err
+	}
+
+	source := DCIMSource{Dir: dir, Volume: FindVolume(params.SrcDir)}
+	if source.Volume.Key() == "" {
+		Logger.Warn("the volume label and serial of the source directory are unknown", "path", params.SrcDir)
+		return source, nil
+	}
+
+	cards, err := ReadImportedCards(CardsFilePath(params))
+	if IsError(err) {
+		return source, err
+	}
+	source.Card = cards[source.Volume.Key()]
+	source.Card.Volume = source.Volume
+
+	return source, nil
+}
+
+// withDCIMSource sets up the DCIM source of the options, when the source type is dcim and it's not set up yet.
+// Every command walking the source directory needs it to filter the files.
+func withDCIMSource(params CmdOptions) (CmdOptions, error) {
+	if params.SourceType != SourceTypeDCIM || params.DCIM.Dir != "" {
+		return params, nil
+	}
+
+	source, err := NewDCIMSource(params)
+	if IsError(err) {
+		return params, err
+	}
+	params.DCIM = source
+
+	return params, nil
+}
+
+// dcimDirSkipReason returns why a directory of a card should not be walked into: only the DCIM directory and its
+// subdirectories (e.g. 100CANON, or Camera on phones) have the shots.
+func dcimDirSkipReason(params CmdOptions, path string) string {
+	dcimDir := params.DCIM.Dir
+	if path == dcimDir {
+		return ""
+	}
+
+	if filepath.Dir(path) != dcimDir || strings.HasPrefix(filepath.Base(path), ".") {
+		return SkipReasonNotDCIM
+	}
+
+	return ""
+}
+
+// dcimFileSkipReason skips the files outside the DCIM directory, and the ones imported from the card before, with
+// --only-new. Since the numbering can be reset in the camera, the files modified after the last import are never
+// skipped.
+func dcimFileSkipReason(params CmdOptions, path string, info os.FileInfo) string {
+	if !strings.HasPrefix(path, params.DCIM.Dir+string(os.PathSeparator)) {
+		return SkipReasonNotDCIM
+	}
+
+	card := params.DCIM.Card
+	if !params.OnlyNew || card.LastNumber == 0 {
+		return ""
+	}
+
+	if number := DCFNumber(path); number > 0 && number <= card.LastNumber && !info.ModTime().After(card.LastImport) {
+		return SkipReasonImportedFromCard
+	}
+
+	return ""
+}
+
+// cardImport tracks the DCF numbers of the files imported from a card, to remember the last one.
+type cardImport struct {
+	lastNumber  int
+	firstFailed int
+}
+
+func (c *cardImport) track(path string, failed bool) {
+	number := DCFNumber(path)
+	if number == 0 {
+		return
+	}
+
+	if failed && (c.firstFailed == 0 || number < c.firstFailed) {
+		c.firstFailed = number
+	}
+	c.lastNumber = max(c.lastNumber, number)
+}
+
+// remember saves the last DCF number imported from the card in the cards file of the destination. The files after
+// the first failed one are not considered imported, so they are imported again next time.
+func (c *cardImport) remember(params CmdOptions) error {
+	card := params.DCIM.Card
+	if params.DryRun || card.Volume.Key() == "" {
+		return nil
+	}
+
+	lastNumber := c.lastNumber
+	if c.firstFailed > 0 {
+		lastNumber = c.firstFailed - 1
+	}
+	card.LastNumber = max(card.LastNumber, lastNumber)
+	card.LastImport = time.Now()
+
+	path := CardsFilePath(params)
+	cards, err := ReadImportedCards(path)
+	if IsError(err) {
+		return err
+	}
+	cards[card.Volume.Key()] = card
+
+	data, err := JsonEncodePretty(cards)
+	if IsError(err) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), DirPerms); IsError(err) {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, FilePerms)
+}
+
+// CardsFilePath returns the path of the file with the cards imported in the destination. It's not in the metadata
+// directory, which only has the metadata of the imported files.
+func CardsFilePath(params CmdOptions) string {
+	return filepath.Join(params.DestDir, DirState, CardsFile)
+}
+
+// ReadImportedCards reads the cards imported in a destination, by their volume key. The file may not exist yet.
+func ReadImportedCards(path string) (map[string]ImportedCard, error) {
+	cards := map[string]ImportedCard{}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cards, nil
+	}
+	if IsError(err) {
+		return cards, err
+	}
+
+	return cards, json.Unmarshal(data, &cards)
+}
+
+func validateSourceType(params CmdOptions) error {
+	if params.SourceType != SourceTypeDir && params.SourceType != SourceTypeDCIM {
+		return fmt.Errorf("Unknown source type %q, the supported ones are: %v.", params.SourceType, SourceTypes)
+	}
+
+	if params.OnlyNew && params.SourceType != SourceTypeDCIM {
+		return fmt.Errorf("The only new option requires the %s source type.", SourceTypeDCIM)
+	}
+
+	return nil
+}
@@ -0,0 +1,258 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+)
+
+const (
+	DedupeKeepEarliest     = "earliest"      // the file with the earliest creation date
+	DedupeKeepShortestPath = "shortest-path" // the file with the shortest path
+	DedupeKeepGPS          = "gps"           // a file with GPS position, if any
+
+	DedupeActionHardlink = "hardlink"
+	DedupeActionTrash    = "trash"
+)
+
+// DedupeGroup is a group of identical files of the library. All of them but the kept one are redundant.
+type DedupeGroup struct {
+	Checksum   string
+	Size       int64
+	Kept       string
+	Duplicates []string
+}
+
+type librarySidecar struct {
+	Path string
+	Meta FileMeta
+}
+
+// FindLibraryDuplicates groups the identical files of the library and chooses the one to keep in every group,
+// following the keep policy. The metadata of the imported files is used to know their creation date and GPS.
+func FindLibraryDuplicates(ctx context.Context, params CmdOptions, libraryDir string, keep string) ([]DedupeGroup, error) {
+	sidecars, err := librarySidecars(params, libraryDir)
+	if IsError(err) {
+		return nil, err
+	}
+
+	// the library is not a memory card, even if the options say the source is
+	params.SrcDir = libraryDir
+	params.SourceType, params.OnlyNew = SourceTypeDir, false
+	groups, err := FindDuplicates(ctx, params, false, 0)
+	if IsError(err) {
+		return nil, err
+	}
+
+	var dedupeGroups []DedupeGroup
+	for _, group := range groups {
+		files := append([]string{}, group.Files...)
+		sortByKeepPolicy(files, sidecars, keep)
+		dedupeGroups = append(dedupeGroups, DedupeGroup{
+			Checksum:   group.Checksum,
+			Size:       group.Size,
+			Kept:       files[0],
+			Duplicates: files[1:],
+		})
+	}
+
+	return dedupeGroups, nil
+}
+
+// librarySidecars returns the metadata of the imported files, by their path in the library.
+func librarySidecars(params CmdOptions, libraryDir string) (map[string]librarySidecar, error) {
+	sidecars := map[string]librarySidecar{}
+
+	err := WalkLibrary(params, libraryDir, func(metaPath string, file FileMeta) error {
+		sidecars[filepath.Join(libraryDir, file.RelativePath())] = librarySidecar{Path: metaPath, Meta: file}
+		return nil
+	})
+
+	return sidecars, err
+}
+
+func sortByKeepPolicy(files []string, sidecars map[string]librarySidecar, keep string) {
+	creationTime := func(path string) time.Time {
+		if sidecar, ok := sidecars[path]; ok {
+			if t, err := time.Parse(DateFormat, sidecar.Meta.CreationTime); !IsError(err) {
+				return t
+			}
+		}
+		if info, err := os.Stat(path); !IsError(err) {
+			return info.ModTime()
+		}
+		return time.Time{}
+	}
+	hasGPS := func(path string) bool {
+		return sidecars[path].Meta.GPS.Valid()
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+
+		if keep == DedupeKeepGPS && hasGPS(a) != hasGPS(b) {
+			return hasGPS(a)
+		}
+		if keep == DedupeKeepShortestPath && len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		if ta, tb := creationTime(a), creationTime(b); !ta.Equal(tb) {
+			return ta.Before(tb)
+		}
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+}
+
+// DedupeLibrary replaces the redundant files of every group with a hardlink to the kept one, or moves them to the
+// trash directory of the library. The metadata of the kept files is updated to point to them, and lists their
+// hardlinked aliases. It returns the number of deduplicated files and the reclaimed space. When the context is
+// cancelled, it stops before the next file.
+func DedupeLibrary(ctx context.Context, params CmdOptions, libraryDir string, groups []DedupeGroup, action string) (int, int64, error) {
+	sidecars, err := librarySidecars(params, libraryDir)
+	if IsError(err) {
+		return 0, 0, err
+	}
+
+	var deduplicated int
+	var reclaimed int64
+
+	for _, group := range groups {
+		var aliases []string
+		keptSidecar, hasSidecar := groupSidecar(group, sidecars)
+
+		for _, path := range group.Duplicates {
+			// the metadata of the group is still updated with the files deduplicated so far
+			if ctx.Err() != nil {
+				break
+			}
+			if params.DryRun {
+				Logger.Info("duplicate would be deduplicated", "path", path, "kept", group.Kept, "action", action)
+				continue
+			}
+
+			relPath, err := filepath.Rel(libraryDir, path)
+			if IsError(err) {
+				return deduplicated, reclaimed, err
+			}
+
+			switch action {
+			case DedupeActionHardlink:
+				if err := resolveDuplicateFile(group.Kept, path, DupesActionHardlink); IsError(err) {
+					return deduplicated, reclaimed, err
+				}
+				aliases = append(aliases, relPath)
+			case DedupeActionTrash:
+				if err := moveToTrash(ctx, libraryDir, path); IsError(err) {
+					return deduplicated, reclaimed, err
+				}
+			default:
+				return deduplicated, reclaimed, fmt.Errorf("Unknown action %q, the supported ones are: hardlink, trash.", action)
+			}
+
+			// the metadata of the duplicate is not needed anymore, unless it's the one kept for the group
+			if sidecar, ok := sidecars[path]; ok && sidecar.Path != keptSidecar.Path {
+				if err := moveToTrash(ctx, libraryDir, sidecar.Path); IsError(err) {
+					return deduplicated, reclaimed, err
+				}
+			}
+
+			Logger.Info("duplicate deduplicated", "path", path, "kept", group.Kept, "action", action)
+			deduplicated++
+			reclaimed += group.Size
+		}
+
+		if hasSidecar && !params.DryRun {
+			if err := updateKeptSidecar(libraryDir, group, keptSidecar, aliases); IsError(err) {
+				return deduplicated, reclaimed, err
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return deduplicated, reclaimed, err
+		}
+	}
+
+	return deduplicated, reclaimed, nil
+}
+
+// groupSidecar returns the metadata of the kept file or, if it has none, the one of the first duplicate with it.
+func groupSidecar(group DedupeGroup, sidecars map[string]librarySidecar) (librarySidecar, bool) {
+	for _, path := range append([]string{group.Kept}, group.Duplicates...) {
+		if sidecar, ok := sidecars[path]; ok {
+			return sidecar, true
+		}
+	}
+
+	return librarySidecar{}, false
+}
+
+// updateKeptSidecar makes the metadata of the group point to the kept file, since it could be the one of a
+// duplicate, e.g. when they share the same checksum, and adds the new aliases.
+func updateKeptSidecar(libraryDir string, group DedupeGroup, sidecar librarySidecar, aliases []string) error {
+	relPath, err := filepath.Rel(libraryDir, group.Kept)
+	if IsError(err) {
+		return err
+	}
+
+	file := sidecar.Meta
+	ext := filepath.Ext(relPath)
+	file.Destination = FilePathInfo{
+		Path:      group.Kept,
+		Basename:  filepath.Base(relPath[:len(relPath)-len(ext)]),
+		Dirname:   filepath.ToSlash(filepath.Dir(relPath)),
+		Extension: ext,
+	}
+
+	for _, alias := range aliases {
+		if !slices.Contains(file.Aliases, alias) && alias != relPath {
+			file.Aliases = append(file.Aliases, alias)
+		}
+	}
+
+	meta, err := JsonEncodePretty(file)
+	if IsError(err) {
+		return err
+	}
+
+	return ioutil.WriteFile(sidecar.Path, meta, FilePerms)
+}
+
+// moveToTrash moves a file of the library to its trash directory, keeping its relative path.
+func moveToTrash(ctx context.Context, libraryDir string, path string) error {
+	relPath, err := filepath.Rel(libraryDir, path)
+	if IsError(err) {
+		return err
+	}
+
+	dest := filepath.Join(libraryDir, DirTrash, relPath)
+	if err := os.MkdirAll(filepath.Dir(dest), DirPerms); IsError(err) {
+		return err
+	}
+
+	return FileMove(ctx, path, dest)
+}
+
+// ValidateDedupeOptions checks the keep policy and the action of the dedupe command.
+func ValidateDedupeOptions(keep string, action string) error {
+	switch keep {
+	case DedupeKeepEarliest, DedupeKeepShortestPath, DedupeKeepGPS:
+	default:
+		return fmt.Errorf("Unknown keep policy %q, the supported ones are: earliest, shortest-path, gps.", keep)
+	}
+
+	switch action {
+	case DedupeActionHardlink, DedupeActionTrash:
+	default:
+		return fmt.Errorf("Unknown action %q, the supported ones are: hardlink, trash.", action)
+	}
+
+	return nil
+}
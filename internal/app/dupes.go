@@ -0,0 +1,286 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+)
+
+const (
+	DupesActionHardlink = "hardlink"
+	DupesActionSymlink  = "symlink"
+	DupesActionDelete   = "delete"
+)
+
+// DuplicateGroup is a group of identical files, or of near-identical images when Similar is set.
+// The first file is the one to keep: the oldest, or the one with the shortest path.
+type DuplicateGroup struct {
+	Checksum string
+	Similar  bool
+	Size     int64 // size of every file, for identical files
+	Files    []string
+}
+
+// ReclaimableSize is the space that would be freed by removing all the files of the group but the first one.
+func (g DuplicateGroup) ReclaimableSize() int64 {
+	if g.Similar {
+		return 0
+	}
+
+	return g.Size * int64(len(g.Files)-1)
+}
+
+type dupesFile struct {
+	Path string
+	Info os.FileInfo
+}
+
+// FindDuplicates groups the identical media files of the source directory, using the same filters as the import.
+// Only files with the same size are compared by checksum. With similar, it also groups the images whose
+// difference hash is within the given distance.
+func FindDuplicates(ctx context.Context, params CmdOptions, similar bool, maxDistance int) ([]DuplicateGroup, error) {
+	bySize := map[int64][]dupesFile{}
+	var images []dupesFile
+
+	_, err := walkDir(ctx, params, func(stats *CmdFileStats, path string, info os.FileInfo, err error) error {
+		if IsError(err) {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		// hardlinks of the same file are not duplicates, they don't take any extra space
+		for _, other := range bySize[info.Size()] {
+			if os.SameFile(info, other.Info) {
+				return nil
+			}
+		}
+
+		file := dupesFile{Path: path, Info: info}
+		bySize[info.Size()] = append(bySize[info.Size()], file)
+		if similar && getMediaType(filepath.Ext(path)) == MediaTypeImage {
+			images = append(images, file)
+		}
+
+		return nil
+	})
+	if IsError(err) {
+		return nil, err
+	}
+
+	var groups []DuplicateGroup
+	identical := map[string]bool{} // redundant copies, which don't need to be compared as similar images
+
+	for size, files := range bySize {
+		if len(files) < 2 {
+			continue
+		}
+
+		byChecksum := map[string][]dupesFile{}
+		for _, file := range files {
+			checksum, err := FileCalcChecksum(ctx, file.Path)
+			if isCancelled(ctx, err) {
+				return nil, err
+			}
+			// the file can be removed while looking for duplicates
+			if IsError(err) {
+				Logger.Warn("file not compared", "path", file.Path, "error", err)
+				continue
+			}
+			byChecksum[checksum] = append(byChecksum[checksum], file)
+		}
+
+		for checksum, files := range byChecksum {
+			if len(files) < 2 {
+				continue
+			}
+			group := DuplicateGroup{Checksum: checksum, Size: size, Files: sortDupesFiles(files)}
+			for _, path := range group.Files[1:] {
+				identical[path] = true
+			}
+			groups = append(groups, group)
+		}
+	}
+
+	if similar {
+		similarGroups, err := findSimilarImages(ctx, images, identical, maxDistance)
+		if IsError(err) {
+			return nil, err
+		}
+		groups = append(groups, similarGroups...)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Similar != groups[j].Similar {
+			return !groups[i].Similar
+		}
+		return groups[i].Files[0] < groups[j].Files[0]
+	})
+
+	return groups, nil
+}
+
+func findSimilarImages(ctx context.Context, images []dupesFile, identical map[string]bool, maxDistance int) ([]DuplicateGroup, error) {
+	var files []dupesFile
+	var hashes []ImageHash
+
+	for _, file := range images {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if identical[file.Path] {
+			continue
+		}
+		hash, err := CalcImageHash(file.Path)
+		if IsError(err) {
+			Logger.Debug("image hash failed", "path", file.Path, "error", err)
+			continue
+		}
+		files = append(files, file)
+		hashes = append(hashes, hash)
+	}
+
+	// union-find of the images within the distance of each other
+	parent := make([]int, len(files))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range files {
+		for j := i + 1; j < len(files); j++ {
+			if hashes[i].Distance(hashes[j]) <= maxDistance {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	clusters := map[int][]dupesFile{}
+	for i, file := range files {
+		clusters[find(i)] = append(clusters[find(i)], file)
+	}
+
+	var groups []DuplicateGroup
+	for _, cluster := range clusters {
+		if len(cluster) > 1 {
+			groups = append(groups, DuplicateGroup{Similar: true, Files: sortDupesFiles(cluster)})
+		}
+	}
+
+	return groups, nil
+}
+
+// sortDupesFiles sorts the files by modification time and path length, so the original is usually the first one.
+func sortDupesFiles(files []dupesFile) []string {
+	sort.SliceStable(files, func(i, j int) bool {
+		if !files[i].Info.ModTime().Equal(files[j].Info.ModTime()) {
+			return files[i].Info.ModTime().Before(files[j].Info.ModTime())
+		}
+		if len(files[i].Path) != len(files[j].Path) {
+			return len(files[i].Path) < len(files[j].Path)
+		}
+		return files[i].Path < files[j].Path
+	})
+
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.Path
+	}
+
+	return paths
+}
+
+// ResolveDuplicateGroups replaces the redundant copies of every group of identical files with a hardlink or a
+// symlink to the first file, or deletes them. Similar images are never touched, since they are not the same file.
+// It returns the number of replaced or deleted files and the reclaimed space. When the context is cancelled, it stops
+// before the next file.
+func ResolveDuplicateGroups(ctx context.Context, params CmdOptions, groups []DuplicateGroup, action string) (int, int64, error) {
+	var resolved int
+	var reclaimed int64
+
+	for _, group := range groups {
+		if group.Similar {
+			continue
+		}
+
+		kept := group.Files[0]
+		for _, path := range group.Files[1:] {
+			if err := ctx.Err(); err != nil {
+				return resolved, reclaimed, err
+			}
+			if params.DryRun {
+				Logger.Info("duplicate would be resolved", "path", path, "kept", kept, "action", action)
+				continue
+			}
+
+			err := resolveDuplicateFile(kept, path, action)
+			if errors.Is(err, syscall.EXDEV) {
+				Logger.Warn("duplicate cannot be hardlinked to another filesystem", "path", path, "kept", kept)
+				continue
+			}
+			if IsError(err) {
+				return resolved, reclaimed, err
+			}
+
+			Logger.Info("duplicate resolved", "path", path, "kept", kept, "action", action)
+			resolved++
+			reclaimed += group.Size
+		}
+	}
+
+	return resolved, reclaimed, nil
+}
+
+func resolveDuplicateFile(kept string, path string, action string) error {
+	if action == DupesActionDelete {
+		return os.Remove(path)
+	}
+
+	// the link is created next to the file and then renamed over it, so the file is never missing
+	tmpPath := path + ".mediatidy-tmp"
+	var err error
+
+	switch action {
+	case DupesActionHardlink:
+		err = os.Link(kept, tmpPath)
+	case DupesActionSymlink:
+		var target string
+		target, err = filepath.Abs(kept)
+		if !IsError(err) {
+			err = os.Symlink(target, tmpPath)
+		}
+	default:
+		return fmt.Errorf("Unknown action %q, the supported ones are: hardlink, symlink, delete.", action)
+	}
+	if IsError(err) {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); IsError(err) {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
+
+// ValidateDupesAction checks the action to take with the redundant copies, if any.
+func ValidateDupesAction(action string) error {
+	switch action {
+	case "", DupesActionHardlink, DupesActionSymlink, DupesActionDelete:
+		return nil
+	}
+
+	return fmt.Errorf("Unknown action %q, the supported ones are: hardlink, symlink, delete.", action)
+}
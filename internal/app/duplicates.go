@@ -0,0 +1,185 @@
+package app
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	DuplicateMatchChecksum = "checksum" // a file with the same content was already imported
+	DuplicateMatchPath     = "path"     // the destination file already exists
+
+	DuplicatesFormatCSV  = "csv"
+	DuplicatesFormatJSON = "json"
+
+	DuplicatesActionQuarantine = "quarantine"
+	DuplicatesActionDelete     = "delete"
+)
+
+// WriteDuplicates writes the list of duplicates found in the source directory as csv or json.
+func WriteDuplicates(w io.Writer, duplicates []DuplicateRecord, format string) error {
+	switch format {
+	case DuplicatesFormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"source", "duplicate_of", "match"}); IsError(err) {
+			return err
+		}
+		for _, duplicate := range duplicates {
+			if err := cw.Write([]string{duplicate.Source, duplicate.DuplicateOf, duplicate.Match}); IsError(err) {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case DuplicatesFormatJSON:
+		if duplicates == nil {
+			duplicates = []DuplicateRecord{}
+		}
+		data, err := JsonEncodePretty(duplicates)
+		if IsError(err) {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	}
+
+	return fmt.Errorf("Unknown duplicates format %q, the supported ones are: csv, json.", format)
+}
+
+// ResolveDuplicates moves the source duplicates to the quarantine directory, keeping their path relative to the
+// source directory, or deletes them, depending on the duplicates action. It returns the number of resolved files.
+func ResolveDuplicates(ctx context.Context, params CmdOptions, duplicates []DuplicateRecord) (int, error) {
+	resolved := 0
+
+	// otherwise, the quarantined files would be imported again in the next run
+	if params.DuplicatesAction == DuplicatesActionQuarantine &&
+		strings.HasPrefix(QuarantineDir(params)+"/", strings.TrimSuffix(params.SrcDir, "/")+"/") {
+		return resolved, errors.New("The quarantine directory cannot be inside the source directory.")
+	}
+
+	for _, duplicate := range duplicates {
+		// the library file must still be there, so the content is not lost
+		if !PathExists(duplicate.Source) || !hasLibraryCopy(params, duplicate) {
+			Logger.Warn("duplicate not resolved, the library file is missing or links to the source",
+				"path", duplicate.Source, "duplicate_of", duplicate.DuplicateOf)
+			continue
+		}
+
+		// a file at the same destination path can be a different one, e.g. an edited JPEG of a RAW+JPEG pair
+		if duplicate.Match != DuplicateMatchChecksum && !hasSameContent(ctx, duplicate.Source, duplicate.DuplicateOf) {
+			if err := ctx.Err(); err != nil {
+				return resolved, err
+			}
+			Logger.Warn("duplicate not resolved, the library file has a different content",
+				"path", duplicate.Source, "duplicate_of", duplicate.DuplicateOf)
+			continue
+		}
+
+		switch params.DuplicatesAction {
+		case DuplicatesActionQuarantine:
+			relPath, err := filepath.Rel(params.SrcDir, duplicate.Source)
+			if IsError(err) {
+				return resolved, err
+			}
+			dest := filepath.Join(QuarantineDir(params), relPath)
+			if err := os.MkdirAll(filepath.Dir(dest), DirPerms); IsError(err) {
+				return resolved, err
+			}
+			if err := FileMove(ctx, duplicate.Source, dest); IsError(err) {
+				return resolved, err
+			}
+			Logger.Info("duplicate quarantined", "path", duplicate.Source, "destination", dest)
+		case DuplicatesActionDelete:
+			if err := os.Remove(duplicate.Source); IsError(err) {
+				return resolved, err
+			}
+			Logger.Info("duplicate deleted", "path", duplicate.Source, "duplicate_of", duplicate.DuplicateOf)
+		default:
+			return resolved, nil
+		}
+
+		resolved++
+	}
+
+	return resolved, nil
+}
+
+// hasLibraryCopy tells if the library file of a duplicate has its own copy of the content. Symlinks to the source
+// directory, e.g. imported with the symlink mode, would be left dangling once the source duplicate is removed.
+// Hardlinks share the content, so they are fine.
+func hasLibraryCopy(params CmdOptions, duplicate DuplicateRecord) bool {
+	info, err := os.Lstat(duplicate.DuplicateOf)
+	if IsError(err) {
+		return false
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := filepath.EvalSymlinks(duplicate.DuplicateOf)
+		if IsError(err) {
+			return false
+		}
+		srcDir, err := filepath.EvalSymlinks(params.SrcDir)
+		if IsError(err) {
+			srcDir = params.SrcDir
+		}
+		source, err := filepath.EvalSymlinks(duplicate.Source)
+		if IsError(err) {
+			source = duplicate.Source
+		}
+		if target == source || isPathInside(target, srcDir) {
+			return false
+		}
+	}
+
+	if duplicate.Metadata != "" && PathExists(duplicate.Metadata) {
+		if file, err := ReadFileMeta(duplicate.Metadata); !IsError(err) && file.ImportMode == ModeSymlink {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hasSameContent tells if two files have the same checksum.
+func hasSameContent(ctx context.Context, path string, otherPath string) bool {
+	if !PathExists(path) || !PathExists(otherPath) {
+		return false
+	}
+
+	checksum, err := FileCalcChecksum(ctx, path)
+	if IsError(err) {
+		return false
+	}
+	otherChecksum, err := FileCalcChecksum(ctx, otherPath)
+
+	return !IsError(err) && checksum == otherChecksum
+}
+
+// QuarantineDir returns the directory where the source duplicates are moved to, by default in the destination.
+func QuarantineDir(params CmdOptions) string {
+	if params.QuarantineDir != "" {
+		return params.QuarantineDir
+	}
+
+	return filepath.Join(params.DestDir, DirQuarantine)
+}
+
+func validateDuplicatesOptions(params CmdOptions) error {
+	if params.DuplicatesFormat != DuplicatesFormatCSV && params.DuplicatesFormat != DuplicatesFormatJSON {
+		return fmt.Errorf("Unknown duplicates format %q, the supported ones are: csv, json.", params.DuplicatesFormat)
+	}
+
+	switch params.DuplicatesAction {
+	case "", DuplicatesActionQuarantine, DuplicatesActionDelete:
+	default:
+		return fmt.Errorf("Unknown duplicates action %q, the supported ones are: quarantine, delete.", params.DuplicatesAction)
+	}
+
+	return nil
+}
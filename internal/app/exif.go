@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -8,6 +9,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -25,24 +27,31 @@ type ExifToolData struct {
 	FilePermissions   string
 	MIMEType          string
 	// Exif / XMP info:
-	Make              string
-	Model             string
-	Software          string
-	CreatorTool       string
-	CreateDate        string
-	ModifyDate        string
-	DateTimeOriginal  string
-	DateTimeDigitized string
-	ImageWidth        string
-	ImageHeight       string
-	ImageSize         string
-	GPSAltitude       string
-	GPSLatitude       string
-	GPSLongitude      string
-	GPSLatitudeRef    string
-	GPSLongitudeRef   string
-	GPSPosition       string
-	GPSDateTime       string
+	Make                string
+	Model               string
+	Software            string
+	CreatorTool         string
+	CreateDate          string
+	ModifyDate          string
+	DateTimeOriginal    string
+	DateTimeDigitized   string
+	OffsetTime          string
+	OffsetTimeOriginal  string
+	OffsetTimeDigitized string
+	CreationDate        string
+	MediaCreateDate     string
+	TrackCreateDate     string
+	ImageWidth          string
+	ImageHeight         string
+	ImageSize           string
+	GPSAltitude         string
+	GPSLatitude         string
+	GPSLongitude        string
+	GPSLatitudeRef      string
+	GPSLongitudeRef     string
+	GPSPosition         string
+	GPSDateTime         string
+	Duration            string
 }
 
 type ExifData struct {
@@ -51,7 +60,62 @@ type ExifData struct {
 	DataDumpRaw string
 }
 
-func GetFileMetadata(params CmdOptions, path string, info os.FileInfo) (FileMeta, error) {
+func GetFileMetadata(ctx context.Context, params CmdOptions, path string, info os.FileInfo) (FileMeta, error) {
+	// The metadata is incomplete if the reading was interrupted
+	fdata, err := readFileMeta(ctx, params, path, info)
+	if IsError(err) {
+		return fdata, err
+	}
+
+	if params.SourceType == SourceTypeDCIM {
+		fdata.Volume = params.DCIM.Volume
+	}
+
+	// Find the other half of a RAW+JPEG pair, so both files share the same destination name
+	fdata.PairedWith, fdata.PairChecksum = findPairedFile(ctx, params, fdata)
+	if err := ctx.Err(); err != nil {
+		return fdata, err
+	}
+	params.PairCache.rememberFile(info, fdata)
+
+	// Build Destination file name and dirName
+	fdata.Destination, fdata.MatchedRule = buildDestination(params, fdata)
+	fdata.MetadataPath = buildChecksumPath(params, params.DestDir, fdata.Checksum, fdata.Source.Extension)
+	alreadyExists := PathExists(fdata.Destination.Path) || PathExists(fdata.MetadataPath.Path)
+
+	if alreadyExists {
+		// Detect duplication by checksum or Destination path (e.g. when trying to copy twice from same folder)
+		fdata.DuplicateOf = fdata.Destination.Path
+		fdata.DuplicateMatch = DuplicateMatchPath
+		if PathExists(fdata.MetadataPath.Path) {
+			fdata.DuplicateMatch = DuplicateMatchChecksum
+			if existing, err := ReadFileMeta(fdata.MetadataPath.Path); !IsError(err) && existing.Destination.Path != "" {
+				fdata.DuplicateOf = existing.Destination.Path
+			}
+		}
+		if filepath.Base(path) == filepath.Base(fdata.Destination.Path) {
+			// skip storing duplicate if same filename
+			fdata.IsAlreadyImported = true
+			return fdata, nil
+		}
+		fdata.IsDuplication = true
+		return fdata, nil
+	}
+
+	return fdata, nil
+}
+
+func readFileMeta(ctx context.Context, params CmdOptions, path string, info os.FileInfo) (FileMeta, error) {
+	// The metadata of a pair file can be read before, when the other half of the pair was processed
+	if fdata, ok := params.PairCache.take(path, info); ok {
+		return fdata, nil
+	}
+
+	checksum, err := FileCalcChecksum(ctx, path)
+	if IsError(err) {
+		return FileMeta{}, err
+	}
+
 	ext := strings.ToLower(filepath.Ext(path))
 
 	fdata := FileMeta{
@@ -62,50 +126,68 @@ func GetFileMetadata(params CmdOptions, path string, info os.FileInfo) (FileMeta
 			Extension: ext,
 		},
 		Size:              info.Size(),
-		Checksum:          FileCalcChecksum(path),
+		Checksum:          checksum,
 		MediaType:         getMediaType(ext),
+		IsRaw:             regexp.MustCompile(RegexImageRaw).MatchString(ext),
 		IsDuplication:     false,
 		IsAlreadyImported: false,
 	}
 
-	// Parse metadata
-	fdata.Exif = parseMetadata(params, fdata)
-	fdata.GPS = GPSDataParse(fdata.Exif.Data.GPSPosition)
-
-	// Find file times
-	fdata.ModificationTime = info.ModTime().Format(DateFormat)
-	fdata.CreationTime = fdata.ModificationTime
-	fdata.CreationTime = parseEarliestCreationDate(fdata)
+	// Parse metadata and detect the real file type
+	fdata.Exif = parseMetadata(ctx, params, fdata)
+	fdata.MediaType, fdata.MimeType, fdata.DetectedExtension = detectFileType(fdata)
+	fdata.IsRaw = fdata.IsRaw || regexp.MustCompile(RegexImageRaw).MatchString(fdata.DetectedExtension)
+	fdata.GPS = GPSDataParse(fdata.Exif.Data)
 
 	// Find creation tool, camera, topic
 	fdata.CameraModel = parseExifCameraName(fdata.Exif.Data)
 	fdata.CreationTool = parseExifCreationTool(fdata.Exif.Data)
-	fdata.IsScreenShot = isScreenShot(fdata.Source.Path +
-		":" + fdata.Exif.Data.SourceFile +
-		":" + fdata.Exif.Data.CreatorTool +
-		":" + fdata.CameraModel)
 
-	// Build Destination file name and dirName
-	fdata.Destination = buildDestination(params.DestDir, fdata)
-	fdata.MetadataPath = buildChecksumPath(params.DestDir, fdata.Checksum, fdata.Source.Extension)
-	alreadyExists := PathExists(fdata.Destination.Path) || PathExists(fdata.MetadataPath.Path)
+	// Find timezone and file times
+	fdata.ModificationTime = info.ModTime().Format(DateFormat)
+	creationTime, dateSource, dateConfidence := resolveFileDate(params, &fdata)
 
-	if alreadyExists {
-		// Detect duplication by checksum or Destination path (e.g. when trying to copy twice from same folder)
-		if filepath.Base(path) == filepath.Base(fdata.Destination.Path) {
-			// skip storing duplicate if same filename
-			fdata.IsAlreadyImported = true
-			return fdata, nil
+	// Geotag the files without GPS from the track logs, which can change their timezone
+	if fdata.GPS.PositionSource != GPSSourceExif && len(params.GPXTrack) > 0 && geotagFromTrack(params, &fdata, creationTime) {
+		creationTime, dateSource, dateConfidence = resolveFileDate(params, &fdata)
+	}
+
+	if fdata.GPS.HasPosition {
+		fdata.Location, _ = ReverseGeocode(fdata.GPS.Position, params.GeocodeMaxDistance)
+	}
+
+	fdata.CreationTime = FormatDateWithTimezone(creationTime, fdata.GPS.Timezone)
+	fdata.DateSource = dateSource
+	fdata.DateConfidence = dateConfidence
+	fdata.Duration = parseExifDuration(fdata.Exif.Data.Duration)
+	fdata.IsScreenShot = isScreenShot(params, fdata)
+	fdata.Category = detectCategory(params, fdata)
+
+	// The metadata is incomplete if the context was cancelled while reading it
+	return fdata, ctx.Err()
+}
+
+// resolveFileDate resolves the timezone and creation date of the file, corrected by the camera time shifts.
+func resolveFileDate(params CmdOptions, fdata *FileMeta) (time.Time, string, string) {
+	fdata.GPS.Timezone, fdata.GPS.TimezoneSource = resolveTimezone(params, *fdata, time.Time{})
+	creationTime, dateSource, dateConfidence := resolveCreationDate(params, *fdata)
+
+	if fdata.GPS.TimezoneSource == TimezoneSourceDefault {
+		// now that the date is known, check if it was taken during a trip, and resolve it again in that timezone
+		fdata.GPS.Timezone, fdata.GPS.TimezoneSource = resolveTimezone(params, *fdata, creationTime)
+		if fdata.GPS.TimezoneSource != TimezoneSourceDefault {
+			creationTime, dateSource, dateConfidence = resolveCreationDate(params, *fdata)
 		}
-		fdata.IsDuplication = true
-		return fdata, nil
 	}
 
-	return fdata, nil
+	// Correct the camera clock, if needed
+	creationTime, fdata.TimeShift = applyTimeShift(params, fdata.CameraModel, creationTime)
+
+	return creationTime, dateSource, dateConfidence
 }
 
-func buildChecksumPath(destDirRoot string, checksum string, fileExtension string) FilePathInfo {
-	checksumRelDir := fmt.Sprintf("%s/%s/%s", DirMetadata, checksum[0:2], checksum[2:3])
+func buildChecksumPath(params CmdOptions, destDirRoot string, checksum string, fileExtension string) FilePathInfo {
+	checksumRelDir := fmt.Sprintf("%s/%s/%s", params.DirMetadata, checksum[0:2], checksum[2:3])
 	checksumBaseName := fmt.Sprintf("%s%s", checksum, sanitizeExtension(fileExtension))
 
 	checksumPathInfo := FilePathInfo{
@@ -118,27 +200,46 @@ func buildChecksumPath(destDirRoot string, checksum string, fileExtension string
 	return checksumPathInfo
 }
 
-func buildDestination(destDirRoot string, data FileMeta) FilePathInfo {
+// buildDestination returns the destination of the file and the name of the routing rule that decided it, if any.
+func buildDestination(params CmdOptions, data FileMeta) (FilePathInfo, string) {
 	t, err := time.Parse(time.RFC3339, data.CreationTime)
 	HandleError(err)
 
 	ext := sanitizeExtension(data.Source.Extension)
+	if data.DetectedExtension != "" && (params.FixExtensions || getMediaType(ext) == "") {
+		ext = sanitizeExtension(data.DetectedExtension)
+	}
 
-	var dateFolder, destFilename string
+	var destFilename string
 
-	dateFolder = fmt.Sprintf("%d/%02d", t.Year(), t.Month())
+	checksum := data.Checksum
+	if data.PairChecksum != "" {
+		checksum = data.PairChecksum
+	}
 
 	destFilename = fmt.Sprintf("%d%02d%02d-%02d%02d%02d", t.Year(), t.Month(), t.Day(),
-		t.Hour(), t.Minute(), t.Second()) + "-" + data.Checksum
+		t.Hour(), t.Minute(), t.Second()) + "-" + checksum
+
+	mediaTypeDir := getMediaTypeDir(params, data.MediaType)
+	rule, ruleMatched := matchRoutingRule(params, data)
+	if ruleMatched {
+		mediaTypeDir = rule.Destination
+	} else if routeDir := params.Routes[data.Category]; data.Category != "" && routeDir != "" {
+		mediaTypeDir = routeDir
+	} else if params.SeparateRaw && data.IsRaw {
+		mediaTypeDir = params.DirImagesRaw
+	}
 
-	destDirName := getMediaTypeDir(data.MediaType) + "/" + dateFolder
+	// rule and route directories can have placeholders too
+	mediaTypeDir = expandPathPattern(mediaTypeDir, data, t, "")
+	destDirName := expandPathPattern(params.DestPattern, data, t, mediaTypeDir)
 
 	return FilePathInfo{
 		Basename:  destFilename,
 		Dirname:   destDirName,
 		Extension: ext,
-		Path:      destDirRoot + "/" + destDirName + "/" + destFilename + ext,
-	}
+		Path:      params.DestDir + "/" + destDirName + "/" + destFilename + ext,
+	}, rule.Name
 }
 
 func sanitizeExtension(ext string) string {
@@ -151,14 +252,6 @@ func sanitizeExtension(ext string) string {
 	return ext
 }
 
-func isScreenShot(searchStr string) bool {
-	if regexp.MustCompile(RegexScreenShot).MatchString(searchStr) {
-		return true
-	}
-
-	return false
-}
-
 func getMediaType(ext string) string {
 	if regexp.MustCompile(RegexImage).MatchString(ext) {
 		return MediaTypeImage
@@ -171,12 +264,12 @@ func getMediaType(ext string) string {
 	return ""
 }
 
-func getMediaTypeDir(mediaType string) string {
+func getMediaTypeDir(params CmdOptions, mediaType string) string {
 	switch mediaType {
 	case MediaTypeImage:
-		return DirImages
+		return params.DirImages
 	case MediaTypeVideo:
-		return DirVideos
+		return params.DirVideos
 	}
 
 	return "others"
@@ -196,67 +289,47 @@ func parseExifCreationTool(data ExifToolData) string {
 	return strings.TrimSpace(tool)
 }
 
-func parseExifCameraName(data ExifToolData) string {
-	camera := ""
-
-	if data.Make != "" {
-		camera = data.Make
+// parseExifDuration parses the duration formats of exiftool, like "0:31:22", "12.34 s" or "0:01:05 (approx)",
+// into seconds.
+func parseExifDuration(duration string) float64 {
+	duration = strings.TrimSpace(strings.Replace(duration, "(approx)", "", -1))
+	if duration == "" {
+		return 0
 	}
 
-	if data.Model != "" {
-		camera += " " + data.Model
+	if strings.HasSuffix(duration, " s") {
+		seconds, _ := strconv.ParseFloat(strings.TrimSuffix(duration, " s"), 64)
+		return seconds
 	}
 
-	return strings.TrimSpace(camera)
-}
-
-func parseEarliestCreationDate(data FileMeta) string {
-	var dates [7][2]string
-	var foundDates []time.Time
-	var creationDate time.Time
-
-	metadataDateFormat := "2006:01:02 15:04:05"
-
-	dates[0] = [2]string{data.ModificationTime, DateFormat}
-	dates[1] = [2]string{data.Exif.Data.CreateDate, metadataDateFormat}
-	dates[2] = [2]string{data.Exif.Data.DateTimeOriginal, metadataDateFormat}
-	dates[3] = [2]string{data.Exif.Data.DateTimeDigitized, metadataDateFormat}
-	dates[4] = [2]string{data.Exif.Data.GPSDateTime, metadataDateFormat + "Z"}
-	dates[5] = [2]string{data.Exif.Data.FileModifyDate, metadataDateFormat + "+07:00"}
-
-	for _, val := range dates {
-		if val[0] == "" {
-			continue
-		}
-		t, err := time.Parse(val[1], val[0])
+	seconds := 0.0
+	for _, part := range strings.Split(duration, ":") {
+		value, err := strconv.ParseFloat(part, 64)
 		if IsError(err) {
-			continue
+			return 0
 		}
-
-		foundDates = append(foundDates, t)
+		seconds = seconds*60 + value
 	}
 
-	for i, val := range foundDates {
-		if i == 0 {
-			creationDate = val
-			continue
-		}
+	return seconds
+}
 
-		// find min valid
-		if (val.Year() > 1970) && (val.Unix() < creationDate.Unix()) {
-			creationDate = val
-		}
+func parseExifCameraName(data ExifToolData) string {
+	camera := ""
+
+	if data.Make != "" {
+		camera = data.Make
 	}
 
-	if creationDate.IsZero() {
-		return data.ModificationTime
+	if data.Model != "" {
+		camera += " " + data.Model
 	}
 
-	return FormatDateWithTimezone(creationDate, data.GPS.Timezone)
+	return strings.TrimSpace(camera)
 }
 
-func parseMetadata(params CmdOptions, fdata FileMeta) ExifData {
-	metadataBytes := readExifMetadata(params, fdata)
+func parseMetadata(ctx context.Context, params CmdOptions, fdata FileMeta) ExifData {
+	metadataBytes := readExifMetadata(ctx, params, fdata)
 
 	var metadataByteArr []RawJsonMap
 	jsonerr := json.Unmarshal(metadataBytes, &metadataByteArr)
@@ -297,6 +370,12 @@ func parseExifMetadata(jsonData []byte) ExifToolData {
 	ds.ModifyDate = GetJsonMapValue(d, "ModifyDate")
 	ds.DateTimeOriginal = GetJsonMapValue(d, "DateTimeOriginal")
 	ds.DateTimeDigitized = GetJsonMapValue(d, "DateTimeDigitized")
+	ds.OffsetTime = GetJsonMapValue(d, "OffsetTime")
+	ds.OffsetTimeOriginal = GetJsonMapValue(d, "OffsetTimeOriginal")
+	ds.OffsetTimeDigitized = GetJsonMapValue(d, "OffsetTimeDigitized")
+	ds.CreationDate = GetJsonMapValue(d, "CreationDate")
+	ds.MediaCreateDate = GetJsonMapValue(d, "MediaCreateDate")
+	ds.TrackCreateDate = GetJsonMapValue(d, "TrackCreateDate")
 	ds.ImageWidth = GetJsonMapValue(d, "ImageWidth")
 	ds.ImageHeight = GetJsonMapValue(d, "ImageHeight")
 	ds.ImageSize = GetJsonMapValue(d, "ImageSize")
@@ -307,17 +386,21 @@ func parseExifMetadata(jsonData []byte) ExifToolData {
 	ds.GPSLongitudeRef = GetJsonMapValue(d, "GPSLongitudeRef")
 	ds.GPSPosition = GetJsonMapValue(d, "GPSPosition")
 	ds.GPSDateTime = GetJsonMapValue(d, "GPSDateTime")
+	ds.Duration = GetJsonMapValue(d, "Duration")
 
 	return ds
 }
 
-func readExifMetadata(params CmdOptions, file FileMeta) []byte {
-	// Search for an already existing JSON metadata file
-	pathsLookup := []string{
-		// src, MD5
-		buildChecksumPath(params.SrcDir, file.Checksum, file.Source.Extension).Path,
-		// dest, MD5
-		buildChecksumPath(params.DestDir, file.Checksum, file.Source.Extension).Path,
+func readExifMetadata(ctx context.Context, params CmdOptions, file FileMeta) []byte {
+	// Search for an already existing JSON metadata file, if the checksum of the file is known
+	var pathsLookup []string
+	if file.Checksum != "" {
+		pathsLookup = []string{
+			// src, MD5
+			buildChecksumPath(params, params.SrcDir, file.Checksum, file.Source.Extension).Path,
+			// dest, MD5
+			buildChecksumPath(params, params.DestDir, file.Checksum, file.Source.Extension).Path,
+		}
 	}
 
 	for _, srcMetaFile := range pathsLookup {
@@ -334,7 +417,7 @@ func readExifMetadata(params CmdOptions, file FileMeta) []byte {
 
 	fallbackMetadata := []byte(`[{"SourceFile":"` + file.Source.Path + `", "Error": true}]`)
 
-	jsonBytes, err := exec.Command("exiftool", file.Source.Path, "-json").Output()
+	jsonBytes, err := exec.CommandContext(ctx, "exiftool", file.Source.Path, "-json").Output()
 	if IsError(err) {
 		return fallbackMetadata
 	}
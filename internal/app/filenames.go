@@ -0,0 +1,128 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FilenameDatePattern extracts a date from a file name, using a regex with the named groups "year", "month",
+// "day" and optionally "hour", "minute", "second" and "ampm" (AM or PM, for 12-hour times), or "unix"/"unixms"
+// for timestamps.
+type FilenameDatePattern struct {
+	Name  string `yaml:"name"`
+	Regex string `yaml:"regex"`
+	UTC   bool   `yaml:"utc,omitempty"` // the date in the name is in UTC instead of local time
+}
+
+// DefaultFilenameDatePatterns are the file name formats of common phones, messaging apps and tools.
+var DefaultFilenameDatePatterns = []FilenameDatePattern{
+	{
+		Name:  "whatsapp",
+		Regex: `(?i)^(IMG|VID|AUD|PTT|STK)-(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})-WA\d+`,
+	},
+	{
+		Name:  "pixel",
+		Regex: `(?i)^PXL_(?P<year>\d{4})(?P<month>\d{2})(?P<day>\d{2})_(?P<hour>\d{2})(?P<minute>\d{2})(?P<second>\d{2})`,
+		UTC:   true,
+	},
+	{
+		Name: "macos-screenshot",
+		Regex: `(?i)^Screen ?(shot|recording) (?P<year>\d{4})-(?P<month>\d{2})-(?P<day>\d{2}) at ` +
+			`(?P<hour>\d{1,2})\.(?P<minute>\d{2})\.(?P<second>\d{2})([\s\x{202F}]?(?P<ampm>AM|PM))?`,
+	},
+	{
+		Name: "android",
+		Regex: `(?i)^(IMG|VID|PANO|BURST|MVIMG|Screenshot|Screenrecorder)[-_]?(?P<year>\d{4})-?(?P<month>\d{2})-?(?P<day>\d{2})` +
+			`[-_](?P<hour>\d{2})-?(?P<minute>\d{2})-?(?P<second>\d{2})`,
+	},
+	{
+		Name:  "unix-timestamp",
+		Regex: `(?i)^(FB_IMG_|received_|signal-)?(?P<unixms>1[0-9]{12})([^0-9]|$)`,
+		UTC:   true,
+	},
+	{
+		Name: "generic",
+		Regex: `(^|[^0-9])(?P<year>(19|20)\d{2})[-_.]?(?P<month>0[1-9]|1[0-2])[-_.]?(?P<day>0[1-9]|[12]\d|3[01])` +
+			`([-_ T.]?(?P<hour>[01]\d|2[0-3])[-_.:]?(?P<minute>[0-5]\d)[-_.:]?(?P<second>[0-5]\d))?([^0-9]|$)`,
+	},
+}
+
+// parseFilenameDate tries the patterns in order, returning the date of the first one that matches the file name
+// and the name of the pattern.
+func parseFilenameDate(patterns []FilenameDatePattern, fileName string, loc *time.Location) (time.Time, string, bool) {
+	for _, pattern := range patterns {
+		re := regexp.MustCompile(pattern.Regex)
+		matches := re.FindStringSubmatch(fileName)
+		if matches == nil {
+			continue
+		}
+
+		groups := map[string]int{}
+		ampm := ""
+		for i, name := range re.SubexpNames() {
+			if name == "ampm" {
+				ampm = strings.ToUpper(matches[i])
+			} else if name != "" && matches[i] != "" {
+				groups[name], _ = strconv.Atoi(matches[i])
+			}
+		}
+
+		if ms, ok := groups["unixms"]; ok {
+			return time.UnixMilli(int64(ms)).UTC(), pattern.Name, true
+		}
+		if sec, ok := groups["unix"]; ok {
+			return time.Unix(int64(sec), 0).UTC(), pattern.Name, true
+		}
+
+		// 12 AM is midnight and 12 PM is noon
+		if ampm != "" && (groups["hour"] < 1 || groups["hour"] > 12) {
+			continue
+		}
+		if ampm == "AM" && groups["hour"] == 12 {
+			groups["hour"] = 0
+		} else if ampm == "PM" && groups["hour"] < 12 {
+			groups["hour"] += 12
+		}
+
+		patternLoc := loc
+		if pattern.UTC {
+			patternLoc = time.UTC
+		}
+
+		t := time.Date(groups["year"], time.Month(groups["month"]), groups["day"],
+			groups["hour"], groups["minute"], groups["second"], 0, patternLoc)
+
+		// discard impossible dates like 2019-02-31, which time.Date would normalize
+		if t.Day() != groups["day"] || int(t.Month()) != groups["month"] {
+			continue
+		}
+
+		return t, pattern.Name, true
+	}
+
+	return time.Time{}, "", false
+}
+
+func validateFilenameDatePatterns(patterns []FilenameDatePattern) error {
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern.Regex)
+		if IsError(err) {
+			return fmt.Errorf("Invalid regex in filename date pattern %q: %s", pattern.Name, err)
+		}
+
+		groups := map[string]bool{}
+		for _, name := range re.SubexpNames() {
+			groups[name] = true
+		}
+
+		if !(groups["year"] && groups["month"] && groups["day"]) && !groups["unix"] && !groups["unixms"] {
+			return fmt.Errorf("Filename date pattern %q must have the year, month and day named groups, "+
+				"or a unix or unixms one.", pattern.Name)
+		}
+	}
+
+	return nil
+}
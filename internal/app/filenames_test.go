@@ -0,0 +1,152 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFilenameDate(t *testing.T) {
+	local := time.FixedZone("", 2*3600)
+
+	tests := []struct {
+		fileName    string
+		want        time.Time
+		wantPattern string
+	}{
+		{fileName: ""},
+		{fileName: "IMG_0001.JPG"},
+		{fileName: "holidays.jpg"},
+		{fileName: "IMG-20190231-WA0001.jpg"},
+		{
+			fileName:    "IMG-20190514-WA0012.jpg",
+			want:        time.Date(2019, 5, 14, 0, 0, 0, 0, local),
+			wantPattern: "whatsapp",
+		},
+		{
+			fileName:    "vid-20201231-wa0003.mp4",
+			want:        time.Date(2020, 12, 31, 0, 0, 0, 0, local),
+			wantPattern: "whatsapp",
+		},
+		{
+			fileName:    "PXL_20230415_183045123.jpg",
+			want:        time.Date(2023, 4, 15, 18, 30, 45, 0, time.UTC),
+			wantPattern: "pixel",
+		},
+		{
+			fileName:    "PXL_20230415_183045123.NIGHT.jpg",
+			want:        time.Date(2023, 4, 15, 18, 30, 45, 0, time.UTC),
+			wantPattern: "pixel",
+		},
+		{
+			fileName:    "Screenshot 2021-03-07 at 9.05.31.png",
+			want:        time.Date(2021, 3, 7, 9, 5, 31, 0, local),
+			wantPattern: "macos-screenshot",
+		},
+		{
+			fileName:    "Screen Shot 2019-11-20 at 14.02.09.png",
+			want:        time.Date(2019, 11, 20, 14, 2, 9, 0, local),
+			wantPattern: "macos-screenshot",
+		},
+		{
+			fileName:    "Screenshot 2020-03-01 at 3.14.15 PM.png",
+			want:        time.Date(2020, 3, 1, 15, 14, 15, 0, local),
+			wantPattern: "macos-screenshot",
+		},
+		{
+			fileName:    "Screenshot 2020-03-01 at 3.14.15 AM.png",
+			want:        time.Date(2020, 3, 1, 3, 14, 15, 0, local),
+			wantPattern: "macos-screenshot",
+		},
+		{
+			fileName:    "Screen Shot 2020-03-01 at 12.05.00 AM.png",
+			want:        time.Date(2020, 3, 1, 0, 5, 0, 0, local),
+			wantPattern: "macos-screenshot",
+		},
+		{
+			fileName:    "Screen Shot 2020-03-01 at 12.05.00 PM.png",
+			want:        time.Date(2020, 3, 1, 12, 5, 0, 0, local),
+			wantPattern: "macos-screenshot",
+		},
+		{
+			fileName:    "Screenshot 2020-03-01 at 11.59.59\u202fpm.png",
+			want:        time.Date(2020, 3, 1, 23, 59, 59, 0, local),
+			wantPattern: "macos-screenshot",
+		},
+		{
+			fileName:    "Screen Recording 2022-01-02 at 10.11.12.mov",
+			want:        time.Date(2022, 1, 2, 10, 11, 12, 0, local),
+			wantPattern: "macos-screenshot",
+		},
+		{
+			fileName:    "IMG_20190514_102233.jpg",
+			want:        time.Date(2019, 5, 14, 10, 22, 33, 0, local),
+			wantPattern: "android",
+		},
+		{
+			fileName:    "Screenshot_2020-06-01-08-15-00.png",
+			want:        time.Date(2020, 6, 1, 8, 15, 0, 0, local),
+			wantPattern: "android",
+		},
+		{
+			fileName:    "FB_IMG_1557829353000.jpg",
+			want:        time.Date(2019, 5, 14, 10, 22, 33, 0, time.UTC),
+			wantPattern: "unix-timestamp",
+		},
+		{
+			fileName:    "1557829353123.jpg",
+			want:        time.Date(2019, 5, 14, 10, 22, 33, 123000000, time.UTC),
+			wantPattern: "unix-timestamp",
+		},
+		{fileName: "15578293531234.jpg"},
+		{
+			fileName:    "trip 2018-07-21 beach.jpg",
+			want:        time.Date(2018, 7, 21, 0, 0, 0, 0, local),
+			wantPattern: "generic",
+		},
+		{
+			fileName:    "DSC_20180721T101112.NEF",
+			want:        time.Date(2018, 7, 21, 10, 11, 12, 0, local),
+			wantPattern: "generic",
+		},
+		{fileName: "scan-20181341.jpg"},
+	}
+
+	for _, tt := range tests {
+		got, pattern, ok := parseFilenameDate(DefaultFilenameDatePatterns, tt.fileName, local)
+		if ok != (tt.wantPattern != "") || pattern != tt.wantPattern || !got.Equal(tt.want) {
+			t.Errorf("parseFilenameDate(%q) = %v, %q, %v, want %v, %q", tt.fileName, got, pattern, ok, tt.want, tt.wantPattern)
+		}
+	}
+}
+
+func TestParseFilenameDateUnixSeconds(t *testing.T) {
+	patterns := []FilenameDatePattern{{Name: "seconds", Regex: `^(?P<unix>[0-9]{10})\.`}}
+
+	got, pattern, ok := parseFilenameDate(patterns, "1557829353.jpg", time.Local)
+	if !ok || pattern != "seconds" || !got.Equal(time.Date(2019, 5, 14, 10, 22, 33, 0, time.UTC)) {
+		t.Errorf("parseFilenameDate() = %v, %q, %v", got, pattern, ok)
+	}
+
+	if _, _, ok := parseFilenameDate(patterns, "IMG_0001.jpg", time.Local); ok {
+		t.Errorf("parseFilenameDate() matched a name without timestamp")
+	}
+}
+
+func TestValidateFilenameDatePatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []FilenameDatePattern
+		wantErr  bool
+	}{
+		{name: "defaults", patterns: DefaultFilenameDatePatterns},
+		{name: "invalid regex", patterns: []FilenameDatePattern{{Name: "bad", Regex: `(?P<year>\d{4}`}}, wantErr: true},
+		{name: "missing day", patterns: []FilenameDatePattern{{Name: "bad", Regex: `(?P<year>\d{4})(?P<month>\d{2})`}}, wantErr: true},
+		{name: "unix", patterns: []FilenameDatePattern{{Name: "ok", Regex: `(?P<unix>\d{10})`}}},
+	}
+
+	for _, tt := range tests {
+		if err := validateFilenameDatePatterns(tt.patterns); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validateFilenameDatePatterns() error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
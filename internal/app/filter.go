@@ -0,0 +1,197 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+const (
+	SkipReasonExcludedDir      = "excluded directory"
+	SkipReasonIgnored          = "excluded by pattern"
+	SkipReasonNotIncluded      = "not matching any include pattern"
+	SkipReasonNotMedia         = "not a media file"
+	SkipReasonTooSmall         = "smaller than the minimum file size"
+	SkipReasonTooLarge         = "larger than the maximum file size"
+	SkipReasonExtension        = "extension not in the allowed list"
+	SkipReasonTooOld           = "created before the --since date"
+	SkipReasonTooNew           = "created after the --until date"
+	SkipReasonAlreadyImported  = "already imported"
+	SkipReasonDuplicate        = "duplicate"
+	SkipReasonMissing          = "missing from the library"
+	SkipReasonNotDCIM          = "outside the DCIM directory"
+	SkipReasonImportedFromCard = "imported from the card before"
+)
+
+// fileFilter decides which files of the source directory are processed, based on the exclude dirs regex,
+// the include/exclude patterns, the .mediatidyignore files, the file extensions and the size limits.
+type fileFilter struct {
+	params   CmdOptions
+	include  []ignorePattern
+	excludes map[string][]ignorePattern // patterns by the directory they are relative to
+}
+
+func newFileFilter(params CmdOptions) *fileFilter {
+	f := &fileFilter{
+		params:   params,
+		include:  parseIgnorePatterns(params.Include),
+		excludes: map[string][]ignorePattern{},
+	}
+	f.excludes[params.SrcDir] = parseIgnorePatterns(params.Exclude)
+
+	return f
+}
+
+// loadIgnoreFile adds the patterns of the .mediatidyignore file of a directory, if it has any.
+func (f *fileFilter) loadIgnoreFile(dir string) error {
+	ignoreFile := filepath.Join(dir, IgnoreFileName)
+	if !PathExists(ignoreFile) {
+		return nil
+	}
+
+	patterns, err := readIgnoreFile(ignoreFile)
+	if IsError(err) {
+		return err
+	}
+	f.excludes[dir] = append(f.excludes[dir], patterns...)
+
+	return nil
+}
+
+// isIgnored checks the path against the patterns of its parent directories, from the source directory down,
+// so the patterns of deeper .mediatidyignore files take precedence.
+func (f *fileFilter) isIgnored(path string, isDir bool) bool {
+	var dirs []string
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		dirs = append([]string{dir}, dirs...)
+		if dir == f.params.SrcDir || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	ignored := false
+	for _, dir := range dirs {
+		patterns, ok := f.excludes[dir]
+		if !ok {
+			continue
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if IsError(err) {
+			continue
+		}
+		if excluded, matched := matchIgnorePatterns(patterns, relPath, isDir); matched {
+			ignored = excluded
+		}
+	}
+
+	return ignored
+}
+
+// dirSkipReason returns why a directory should not be walked into, or an empty string otherwise.
+func (f *fileFilter) dirSkipReason(path string) string {
+	if path == f.params.SrcDir {
+		return ""
+	}
+
+	if f.params.SourceType == SourceTypeDCIM {
+		if reason := dcimDirSkipReason(f.params, path); reason != "" {
+			return reason
+		}
+	}
+
+	if regexp.MustCompile(f.params.ExcludeDirs).MatchString(path + "/") {
+		return SkipReasonExcludedDir
+	}
+
+	if f.isIgnored(path, true) {
+		return SkipReasonIgnored
+	}
+
+	return ""
+}
+
+// fileSkipReason returns why a file should not be processed, or an empty string otherwise.
+func (f *fileFilter) fileSkipReason(path string, info os.FileInfo) string {
+	if regexp.MustCompile(f.params.ExcludeDirs).MatchString(path) {
+		return SkipReasonExcludedDir
+	}
+
+	if f.isIgnored(path, false) {
+		return SkipReasonIgnored
+	}
+
+	if len(f.include) > 0 {
+		relPath, _ := filepath.Rel(f.params.SrcDir, path)
+		if included, _ := matchIgnorePatterns(f.include, relPath, false); !included {
+			return SkipReasonNotIncluded
+		}
+	}
+
+	// Not a media file extension? Check the content, in case it is a media file with a wrong or missing extension
+	if !regexp.MustCompile(RegexImage).MatchString(path) &&
+		!regexp.MustCompile(RegexVideo).MatchString(path) {
+		if mediaType, _ := SniffFileType(path); mediaType == "" {
+			return SkipReasonNotMedia
+		}
+	}
+
+	// File is too small?
+	if info.Size() < f.params.MinFileSize {
+		return SkipReasonTooSmall
+	}
+
+	// File is too large?
+	if f.params.MaxFileSize > 0 && info.Size() > f.params.MaxFileSize {
+		return SkipReasonTooLarge
+	}
+
+	// File extension is in allowed list?
+	if f.params.Extensions != "" && !regexp.MustCompile("(?i)\\.("+f.params.Extensions+")$").MatchString(path) {
+		return SkipReasonExtension
+	}
+
+	if f.params.SourceType == SourceTypeDCIM {
+		return dcimFileSkipReason(f.params, path, info)
+	}
+
+	return ""
+}
+
+// dateSkipReason checks the creation date of a file against the --since and --until dates, which are inclusive.
+func dateSkipReason(params CmdOptions, file FileMeta) string {
+	if params.Since == "" && params.Until == "" {
+		return ""
+	}
+
+	creationTime, err := time.Parse(DateFormat, file.CreationTime)
+	if IsError(err) {
+		return ""
+	}
+
+	if params.Since != "" {
+		since, err := parseFilterDate(params, params.Since)
+		if !IsError(err) && creationTime.Before(since) {
+			return SkipReasonTooOld
+		}
+	}
+
+	if params.Until != "" {
+		until, err := parseFilterDate(params, params.Until)
+		if !IsError(err) && !creationTime.Before(until.AddDate(0, 0, 1)) {
+			return SkipReasonTooNew
+		}
+	}
+
+	return ""
+}
+
+// parseFilterDate parses a YYYY-MM-DD date as the start of that day in the configured timezone.
+func parseFilterDate(params CmdOptions, value string) (time.Time, error) {
+	loc, err := LoadTimezone(params.Timezone)
+	if IsError(err) {
+		loc = time.UTC
+	}
+
+	return time.ParseInLocation(FilterDateFormat, value, loc)
+}
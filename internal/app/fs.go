@@ -1,11 +1,15 @@
 package app
 
 import (
+	"context"
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"strings"
+	"syscall"
 	"time"
 )
 
@@ -19,6 +23,13 @@ func PathExists(dir string) bool {
 	return true
 }
 
+// isPathInside tells if a path is the given directory or is inside it.
+func isPathInside(path string, dir string) bool {
+	dir = strings.TrimSuffix(dir, string(os.PathSeparator))
+
+	return path == dir || strings.HasPrefix(path, dir+string(os.PathSeparator)) || dir == ""
+}
+
 func IsDir(dir string) bool {
 	dirStat, err := os.Stat(dir)
 
@@ -29,18 +40,35 @@ func IsDir(dir string) bool {
 	return dirStat.IsDir()
 }
 
-func FileCalcChecksum(path string) string {
+// contextReader stops reading when the context is cancelled, e.g. to stop hashing or copying a large file.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
+}
+
+// FileCalcChecksum returns the MD5 checksum of a file. It fails with the error of the context if it is cancelled
+// before, or if the file cannot be read, e.g. because it was removed.
+func FileCalcChecksum(ctx context.Context, path string) (string, error) {
 	f, err := os.Open(path)
+	if IsError(err) {
+		return "", err
+	}
 	defer f.Close()
 
-	HandleError(err)
-
 	h := md5.New()
-	if _, err := io.Copy(h, f); IsError(err) {
-		HandleError(err)
+	if _, err := io.Copy(h, contextReader{ctx, f}); IsError(err) {
+		return "", err
 	}
 
-	return fmt.Sprintf("%x", h.Sum(nil))
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
 
 func FileAppend(path, str string) {
@@ -69,9 +97,14 @@ func FileFixDates(path string, creationDate time.Time, modificationDate time.Tim
 	return err
 }
 
-func FileCopy(src, dest string, keepAttributes bool) error {
+// FileCopy copies a file. If the context is cancelled in the middle, the incomplete destination file is removed.
+func FileCopy(ctx context.Context, src, dest string, keepAttributes bool) error {
 	if keepAttributes == true && IsUnix { // windows does not support cp nor preserving attributes
-		err := exec.Command("cp", "-pRP", src, dest).Run()
+		err := exec.CommandContext(ctx, "cp", "-pRP", src, dest).Run()
+		if ctx.Err() != nil {
+			os.Remove(dest)
+			return ctx.Err()
+		}
 
 		return err
 	}
@@ -85,21 +118,28 @@ func FileCopy(src, dest string, keepAttributes bool) error {
 	if IsError(err) {
 		return err
 	}
-	if _, err := io.Copy(d, s); IsError(err) {
+	if _, err := io.Copy(d, contextReader{ctx, s}); IsError(err) {
 		d.Close()
+		if ctx.Err() != nil {
+			os.Remove(dest)
+		}
 		return err
 	}
 	return d.Close()
 }
 
-func FileMove(src, dest string) error {
+func FileMove(ctx context.Context, src, dest string) error {
 	err := os.Rename(src, dest)
 
-	if IsError(err) {
-		return err
+	// files cannot be renamed across filesystems, so they are copied and then removed
+	if errors.Is(err, syscall.EXDEV) {
+		if err := FileCopy(ctx, src, dest, true); IsError(err) {
+			return err
+		}
+		return os.Remove(src)
 	}
 
-	return nil
+	return err
 }
 
 func MakeDirIfNotExists(dir string) {
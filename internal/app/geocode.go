@@ -0,0 +1,127 @@
+package app
+
+import (
+	"bufio"
+	"compress/gzip"
+	"embed"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The gazetteer is a reduced version of the GeoNames cities1000 dump (https://www.geonames.org, CC BY 4.0),
+// with the name, coordinates, country code and region of each city, gzipped. Run `make gazetteer` to regenerate it.
+//
+//go:embed data/cities.tsv.gz data/countries.tsv
+var gazetteerFiles embed.FS
+
+type Location struct {
+	City        string
+	Region      string
+	Country     string
+	CountryCode string
+	Distance    float64 // distance to the city center, in km
+}
+
+type gazetteerCity struct {
+	Name        string
+	Region      string
+	CountryCode string
+	Coords      GPSCoord
+}
+
+var gazetteer struct {
+	once      sync.Once
+	cities    []gazetteerCity
+	countries map[string]string
+}
+
+func loadGazetteer() {
+	gazetteer.countries = map[string]string{}
+
+	readGazetteerFile("data/countries.tsv", func(cols []string) {
+		if len(cols) >= 2 {
+			gazetteer.countries[cols[0]] = cols[1]
+		}
+	})
+
+	readGazetteerFile("data/cities.tsv.gz", func(cols []string) {
+		if len(cols) < 5 {
+			return
+		}
+		lat, err := strconv.ParseFloat(cols[1], 64)
+		if IsError(err) {
+			return
+		}
+		lng, err := strconv.ParseFloat(cols[2], 64)
+		if IsError(err) {
+			return
+		}
+		gazetteer.cities = append(gazetteer.cities, gazetteerCity{
+			Name:        cols[0],
+			Coords:      GPSCoord{lat, lng},
+			CountryCode: cols[3],
+			Region:      cols[4],
+		})
+	})
+
+	// sorted by latitude, to only check the cities within the max distance in latitude
+	sort.Slice(gazetteer.cities, func(i, j int) bool {
+		return gazetteer.cities[i].Coords.Latitude < gazetteer.cities[j].Coords.Latitude
+	})
+}
+
+func readGazetteerFile(name string, parseLine func(cols []string)) {
+	f, err := gazetteerFiles.Open(name)
+	HandleError(err)
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(f)
+		HandleError(err)
+		defer gz.Close()
+		r = gz
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		parseLine(strings.Split(scanner.Text(), "\t"))
+	}
+	HandleError(scanner.Err())
+}
+
+// ReverseGeocode finds the nearest city to the coordinates, offline, as long as it's within maxDistance km.
+func ReverseGeocode(coords GPSCoord, maxDistance float64) (Location, bool) {
+	gazetteer.once.Do(loadGazetteer)
+
+	var nearest *gazetteerCity
+	nearestDistance := maxDistance * 1000
+
+	// 1 degree of latitude is ~111 km, the cities further away in latitude are not checked
+	cities := gazetteer.cities
+	first := sort.Search(len(cities), func(i int) bool {
+		return cities[i].Coords.Latitude >= coords.Latitude-maxDistance/111
+	})
+	for i := first; i < len(cities) && cities[i].Coords.Latitude <= coords.Latitude+maxDistance/111; i++ {
+		city := cities[i]
+		if distance := GPSDistance(coords, city.Coords); distance <= nearestDistance {
+			nearest = &gazetteer.cities[i]
+			nearestDistance = distance
+		}
+	}
+
+	if nearest == nil {
+		return Location{}, false
+	}
+
+	return Location{
+		City:        nearest.Name,
+		Region:      nearest.Region,
+		Country:     gazetteer.countries[nearest.CountryCode],
+		CountryCode: nearest.CountryCode,
+		Distance:    nearestDistance / 1000,
+	}, true
+}
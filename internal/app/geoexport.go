@@ -0,0 +1,208 @@
+package app
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+const (
+	GeoFormatGeoJSON = "geojson"
+	GeoFormatKML     = "kml"
+	GeoFormatGPX     = "gpx"
+)
+
+var GeoFormats = []string{GeoFormatGeoJSON, GeoFormatKML, GeoFormatGPX}
+
+// GeoPoint is a geotagged file of the library.
+type GeoPoint struct {
+	Coords       GPSCoord
+	Altitude     float64
+	HasAltitude  bool
+	CreationTime time.Time
+	CameraModel  string
+	MediaType    string
+	Path         string // relative to the library directory
+	Location     Location
+}
+
+// ExportGeo writes the geotagged files of the library as a map layer in the given format, sorted by creation time,
+// and returns the number of exported files. The Since and Until options filter them by date.
+func ExportGeo(params CmdOptions, libraryDir string, format string, w io.Writer) (int, error) {
+	var points []GeoPoint
+
+	err := WalkLibrary(params, libraryDir, func(metaPath string, file FileMeta) error {
+		if !file.GPS.Valid() || dateSkipReason(params, file) != "" {
+			return nil
+		}
+
+		creationTime, err := time.Parse(DateFormat, file.CreationTime)
+		if IsError(err) {
+			return nil
+		}
+
+		points = append(points, GeoPoint{
+			Coords:       file.GPS.Position,
+			Altitude:     file.GPS.Altitude,
+			HasAltitude:  file.GPS.HasAltitude,
+			CreationTime: creationTime,
+			CameraModel:  file.CameraModel,
+			MediaType:    file.MediaType,
+			Path:         file.RelativePath(),
+			Location:     file.Location,
+		})
+
+		return nil
+	})
+	if IsError(err) {
+		return 0, err
+	}
+
+	sort.SliceStable(points, func(i, j int) bool { return points[i].CreationTime.Before(points[j].CreationTime) })
+
+	switch format {
+	case GeoFormatGeoJSON:
+		err = writeGeoJSON(w, points)
+	case GeoFormatKML:
+		err = writeKML(w, points)
+	case GeoFormatGPX:
+		err = writeGPX(w, points)
+	default:
+		err = fmt.Errorf("Unknown format %q, the supported ones are: %v.", format, GeoFormats)
+	}
+
+	return len(points), err
+}
+
+func writeGeoJSON(w io.Writer, points []GeoPoint) error {
+	type geometry struct {
+		Type        string    `json:"type"`
+		Coordinates []float64 `json:"coordinates"`
+	}
+	type feature struct {
+		Type       string            `json:"type"`
+		Geometry   geometry          `json:"geometry"`
+		Properties map[string]string `json:"properties"`
+	}
+
+	features := []feature{}
+	for _, point := range points {
+		// GeoJSON positions are longitude, latitude and the optional altitude
+		coords := []float64{point.Coords.Longitude, point.Coords.Latitude}
+		if point.HasAltitude {
+			coords = append(coords, point.Altitude)
+		}
+
+		features = append(features, feature{
+			Type:     "Feature",
+			Geometry: geometry{Type: "Point", Coordinates: coords},
+			Properties: map[string]string{
+				"date":    point.CreationTime.Format(DateFormat),
+				"camera":  point.CameraModel,
+				"type":    point.MediaType,
+				"path":    point.Path,
+				"city":    point.Location.City,
+				"country": point.Location.Country,
+			},
+		})
+	}
+
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": features,
+	}, "", "  ")
+	if IsError(err) {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+
+	return err
+}
+
+func writeKML(w io.Writer, points []GeoPoint) error {
+	type data struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value"`
+	}
+	type placemark struct {
+		Name        string `xml:"name"`
+		When        string `xml:"TimeStamp>when"`
+		Data        []data `xml:"ExtendedData>Data"`
+		Coordinates string `xml:"Point>coordinates"`
+	}
+	type kml struct {
+		XMLName    xml.Name    `xml:"kml"`
+		Xmlns      string      `xml:"xmlns,attr"`
+		Name       string      `xml:"Document>name"`
+		Placemarks []placemark `xml:"Document>Placemark"`
+	}
+
+	doc := kml{Xmlns: "http://www.opengis.net/kml/2.2", Name: AppName}
+	for _, point := range points {
+		doc.Placemarks = append(doc.Placemarks, placemark{
+			Name: point.Path,
+			When: point.CreationTime.Format(DateFormat),
+			Data: []data{
+				{Name: "camera", Value: point.CameraModel},
+				{Name: "type", Value: point.MediaType},
+				{Name: "path", Value: point.Path},
+			},
+			Coordinates: fmt.Sprintf("%f,%f,%f", point.Coords.Longitude, point.Coords.Latitude, point.Altitude),
+		})
+	}
+
+	return writeXML(w, doc)
+}
+
+func writeGPX(w io.Writer, points []GeoPoint) error {
+	type waypoint struct {
+		Lat  float64  `xml:"lat,attr"`
+		Lon  float64  `xml:"lon,attr"`
+		Ele  *float64 `xml:"ele,omitempty"`
+		Time string   `xml:"time"`
+		Name string   `xml:"name"`
+		Desc string   `xml:"desc,omitempty"`
+		Type string   `xml:"type,omitempty"`
+	}
+	type gpx struct {
+		XMLName   xml.Name   `xml:"gpx"`
+		Xmlns     string     `xml:"xmlns,attr"`
+		Version   string     `xml:"version,attr"`
+		Creator   string     `xml:"creator,attr"`
+		Waypoints []waypoint `xml:"wpt"`
+	}
+
+	doc := gpx{Xmlns: "http://www.topografix.com/GPX/1/1", Version: "1.1", Creator: AppName}
+	for _, point := range points {
+		wpt := waypoint{
+			Lat:  point.Coords.Latitude,
+			Lon:  point.Coords.Longitude,
+			Time: point.CreationTime.UTC().Format(time.RFC3339),
+			Name: point.Path,
+			Desc: point.CameraModel,
+			Type: point.MediaType,
+		}
+		if point.HasAltitude {
+			altitude := point.Altitude
+			wpt.Ele = &altitude
+		}
+		doc.Waypoints = append(doc.Waypoints, wpt)
+	}
+
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, doc interface{}) error {
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if IsError(err) {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, data)
+
+	return err
+}
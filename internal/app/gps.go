@@ -1,7 +1,10 @@
 package app
 
 import (
+	"errors"
 	"github.com/bradfitz/latlong"
+	"math"
+	"regexp"
 	"strconv"
 	"strings"
 )
@@ -11,53 +14,159 @@ type GPSCoord struct {
 	Longitude float64
 }
 
+const (
+	GPSSourceExif = "exif"
+	GPSSourceGPX  = "gpx"
+)
+
 type GPSData struct {
-	Position GPSCoord
-	Timezone string
+	Position       GPSCoord
+	HasPosition    bool
+	PositionSource string  // exif or gpx:<file name>
+	Altitude       float64 // meters above sea level
+	HasAltitude    bool
+	Timezone       string
+	TimezoneSource string // gps, offset, camera, trip:<name> or default
 }
 
-func GPSDataParse(gpsPosition string) GPSData {
-	if gpsPosition == "" {
-		return GPSData{Timezone: DefaultTimezone}
+// GPSDataParse parses the GPS position of the file, falling back to the separate latitude and longitude tags.
+// Invalid coordinates and the 0,0 "null island", which some devices write when they have no fix, are
+// considered missing.
+func GPSDataParse(exif ExifToolData) GPSData {
+	data := GPSData{}
+
+	coords, err := gpsParseCoords(exif.GPSPosition)
+	if IsError(err) {
+		coords, err = gpsParseLatLng(exif.GPSLatitude, exif.GPSLatitudeRef, exif.GPSLongitude, exif.GPSLongitudeRef)
+	}
+
+	if !IsError(err) && gpsIsValid(coords) {
+		data = GPSDataFromCoords(coords)
+		data.PositionSource = GPSSourceExif
+	}
+
+	if altitude, err := gpsParseAltitude(exif.GPSAltitude); !IsError(err) {
+		data.Altitude = altitude
+		data.HasAltitude = true
 	}
-	data := GPSData{Position: gpsParseCoords(gpsPosition)}
-	data.Timezone = latlong.LookupZoneName(data.Position.Latitude, data.Position.Longitude)
 
 	return data
 }
 
-// parses a string like `39 deg 34' 4.66" N, 2 deg 38' 40.34" E`
-func gpsParseCoords(position string) GPSCoord {
+// Valid tells if the GPS data has a position. The metadata files written before HasPosition existed only have the
+// position, which is zero when the file had none.
+func (g GPSData) Valid() bool {
+	return g.HasPosition || gpsIsValid(g.Position)
+}
+
+// GPSDataFromCoords returns the GPS data of a position, with the timezone of that place.
+func GPSDataFromCoords(coords GPSCoord) GPSData {
+	data := GPSData{Position: coords, HasPosition: true}
+	data.Timezone = latlong.LookupZoneName(coords.Latitude, coords.Longitude)
+	if data.Timezone != "" {
+		data.TimezoneSource = TimezoneSourceGPS
+	}
+
+	return data
+}
+
+// GPSDistance returns the distance between two coordinates in meters, using the haversine formula.
+func GPSDistance(a GPSCoord, b GPSCoord) float64 {
+	const earthRadius = 6371000.0
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(b.Latitude - a.Latitude)
+	dLng := toRad(b.Longitude - a.Longitude)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(a.Latitude))*math.Cos(toRad(b.Latitude))*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+func gpsIsValid(coords GPSCoord) bool {
+	if math.IsNaN(coords.Latitude) || math.IsNaN(coords.Longitude) ||
+		math.Abs(coords.Latitude) > 90 || math.Abs(coords.Longitude) > 180 {
+		return false
+	}
+
+	return math.Abs(coords.Latitude) > 1e-6 || math.Abs(coords.Longitude) > 1e-6
+}
+
+// parses a string like `39 deg 34' 4.66" N, 2 deg 38' 40.34" E` or `39.567961, -2.644539`
+func gpsParseCoords(position string) (GPSCoord, error) {
 	latLng := strings.Split(strings.TrimSpace(position), ",")
 
 	if len(latLng) != 2 {
-		panic("Cannot parse GPS position: " + position)
+		return GPSCoord{}, errors.New("Cannot parse GPS position: " + position)
+	}
+
+	return gpsParseLatLng(latLng[0], "", latLng[1], "")
+}
+
+func gpsParseLatLng(lat string, latRef string, lng string, lngRef string) (GPSCoord, error) {
+	latitude, err := gpsParsePart(lat, latRef)
+	if IsError(err) {
+		return GPSCoord{}, err
 	}
 
-	lat := gpsParsePart(strings.TrimSpace(latLng[0]))
-	lng := gpsParsePart(strings.TrimSpace(latLng[1]))
+	longitude, err := gpsParsePart(lng, lngRef)
+	if IsError(err) {
+		return GPSCoord{}, err
+	}
 
-	return GPSCoord{lat, lng}
+	return GPSCoord{latitude, longitude}, nil
 }
 
-// parses a string like `2 deg 38' 40.34" E`
-func gpsParsePart(val string) float64 {
-	chunks := strings.Split(val, " ")
+// parses a string like `2 deg 38' 40.34" E`, `2°38'40.34"E`, `2 deg 38.67' E`, `2.644539 E` or `-2.644539`.
+// The reference (N, S, E, W, North, etc.) can also be passed separately, like exiftool does with the
+// GPSLatitudeRef and GPSLongitudeRef tags.
+func gpsParsePart(val string, ref string) (float64, error) {
+	val = strings.TrimSpace(val)
+	numbers := regexp.MustCompile(`[-+]?[0-9]+(\.[0-9]+)?`).FindAllString(val, -1)
 
-	if len(chunks) != 5 {
-		panic("Cannot parse GPS position: " + val)
+	if len(numbers) == 0 || len(numbers) > 3 {
+		return 0, errors.New("Cannot parse GPS coordinate: " + val)
 	}
 
-	deg, _ := strconv.ParseFloat(strings.Trim(chunks[0], " '\""), 64)
-	minutes, _ := strconv.ParseFloat(strings.Trim(chunks[2], " '\""), 64)
-	seconds, _ := strconv.ParseFloat(strings.Trim(chunks[3], " '\""), 64)
+	var parts [3]float64
+	for i, number := range numbers {
+		parsed, err := strconv.ParseFloat(number, 64)
+		if IsError(err) {
+			return 0, err
+		}
+		parts[i] = math.Abs(parsed)
+	}
 
-	coord := deg + (minutes / 60) + (seconds / 3600)
-	ref := strings.ToUpper(chunks[4])
+	coord := parts[0] + (parts[1] / 60) + (parts[2] / 3600)
 
-	if (ref == "S") || (ref == "W") { // N is "+", S is "-",  E is "+", W is "-"
+	if hemisphere := regexp.MustCompile(`(?i)[NSEW]\w*\s*$`).FindString(val); hemisphere != "" {
+		ref = hemisphere
+	}
+	ref = strings.ToUpper(strings.TrimSpace(ref))
+
+	// N is "+", S is "-",  E is "+", W is "-"
+	if strings.HasPrefix(numbers[0], "-") || strings.HasPrefix(ref, "S") || strings.HasPrefix(ref, "W") {
 		coord *= -1
 	}
 
-	return coord
+	return coord, nil
+}
+
+// parses a string like `123.4 m Above Sea Level`, `12 m Below Sea Level` or `-12.5`
+func gpsParseAltitude(val string) (float64, error) {
+	number := regexp.MustCompile(`[-+]?[0-9]+(\.[0-9]+)?`).FindString(val)
+	if number == "" {
+		return 0, errors.New("Cannot parse GPS altitude: " + val)
+	}
+
+	altitude, err := strconv.ParseFloat(number, 64)
+	if IsError(err) {
+		return 0, err
+	}
+
+	if strings.Contains(strings.ToLower(val), "below") {
+		altitude = -math.Abs(altitude)
+	}
+
+	return altitude, nil
 }
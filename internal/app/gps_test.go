@@ -0,0 +1,177 @@
+package app
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGPSParsePart(t *testing.T) {
+	tests := []struct {
+		val     string
+		ref     string
+		want    float64
+		wantErr bool
+	}{
+		{val: "", wantErr: true},
+		{val: "garbage", wantErr: true},
+		{val: "1 2 3 4", wantErr: true},
+		{val: `2 deg 38' 40.34" E`, want: 2.644539},
+		{val: `39 deg 34' 4.66" N`, want: 39.567961},
+		{val: `39°34'4.66"S`, want: -39.567961},
+		{val: `2 deg 38.67' W`, want: -2.6445},
+		{val: "2.644539", want: 2.644539},
+		{val: "-2.644539", want: -2.644539},
+		{val: "2.644539 W", want: -2.644539},
+		{val: "2.644539", ref: "West", want: -2.644539},
+		{val: "39.567961", ref: "S", want: -39.567961},
+		{val: "39.567961 N", ref: "S", want: 39.567961},
+	}
+
+	for _, tt := range tests {
+		got, err := gpsParsePart(tt.val, tt.ref)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("gpsParsePart(%q, %q) error = %v, want error %v", tt.val, tt.ref, err, tt.wantErr)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("gpsParsePart(%q, %q) = %f, want %f", tt.val, tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestGPSParseCoords(t *testing.T) {
+	tests := []struct {
+		position string
+		want     GPSCoord
+		wantErr  bool
+	}{
+		{position: "", wantErr: true},
+		{position: "garbage", wantErr: true},
+		{position: "39.567961", wantErr: true},
+		{position: "39.567961, garbage", wantErr: true},
+		{position: `39 deg 34' 4.66" N, 2 deg 38' 40.34" E`, want: GPSCoord{39.567961, 2.644539}},
+		{position: "39.567961, -2.644539", want: GPSCoord{39.567961, -2.644539}},
+		{position: `33°52'4.08"S, 151°12'26.64"E`, want: GPSCoord{-33.8678, 151.2074}},
+	}
+
+	for _, tt := range tests {
+		got, err := gpsParseCoords(tt.position)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("gpsParseCoords(%q) error = %v, want error %v", tt.position, err, tt.wantErr)
+			continue
+		}
+		if math.Abs(got.Latitude-tt.want.Latitude) > 1e-6 || math.Abs(got.Longitude-tt.want.Longitude) > 1e-6 {
+			t.Errorf("gpsParseCoords(%q) = %v, want %v", tt.position, got, tt.want)
+		}
+	}
+}
+
+func TestGPSParseAltitude(t *testing.T) {
+	tests := []struct {
+		val     string
+		want    float64
+		wantErr bool
+	}{
+		{val: "", wantErr: true},
+		{val: "unknown", wantErr: true},
+		{val: "123.4 m Above Sea Level", want: 123.4},
+		{val: "12 m Below Sea Level", want: -12},
+		{val: "-12.5", want: -12.5},
+	}
+
+	for _, tt := range tests {
+		got, err := gpsParseAltitude(tt.val)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("gpsParseAltitude(%q) error = %v, want error %v", tt.val, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("gpsParseAltitude(%q) = %f, want %f", tt.val, got, tt.want)
+		}
+	}
+}
+
+func TestGPSDataParse(t *testing.T) {
+	tests := []struct {
+		name         string
+		exif         ExifToolData
+		wantPosition GPSCoord
+		hasPosition  bool
+		wantAltitude float64
+		hasAltitude  bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "garbage",
+			exif: ExifToolData{GPSPosition: "n/a", GPSLatitude: "n/a", GPSLongitude: "n/a", GPSAltitude: "n/a"},
+		},
+		{
+			name:        "null island",
+			exif:        ExifToolData{GPSPosition: "0, 0", GPSAltitude: "0 m Above Sea Level"},
+			hasAltitude: true,
+		},
+		{
+			name: "out of range",
+			exif: ExifToolData{GPSPosition: "95.1, 200.2"},
+		},
+		{
+			name:         "dms position",
+			exif:         ExifToolData{GPSPosition: `39 deg 34' 4.66" N, 2 deg 38' 40.34" E`, GPSAltitude: "12 m Below Sea Level"},
+			wantPosition: GPSCoord{39.567961, 2.644539},
+			hasPosition:  true,
+			wantAltitude: -12,
+			hasAltitude:  true,
+		},
+		{
+			name:         "decimal position",
+			exif:         ExifToolData{GPSPosition: "40.416775, -3.703790"},
+			wantPosition: GPSCoord{40.416775, -3.703790},
+			hasPosition:  true,
+		},
+		{
+			name: "separate latitude and longitude",
+			exif: ExifToolData{
+				GPSLatitude: "37.7749", GPSLatitudeRef: "North", GPSLongitude: "122.4194", GPSLongitudeRef: "West",
+			},
+			wantPosition: GPSCoord{37.7749, -122.4194},
+			hasPosition:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		got := GPSDataParse(tt.exif)
+		if got.HasPosition != tt.hasPosition || got.Valid() != tt.hasPosition {
+			t.Errorf("%s: HasPosition = %v, Valid() = %v, want %v", tt.name, got.HasPosition, got.Valid(), tt.hasPosition)
+		}
+		if math.Abs(got.Position.Latitude-tt.wantPosition.Latitude) > 1e-6 ||
+			math.Abs(got.Position.Longitude-tt.wantPosition.Longitude) > 1e-6 {
+			t.Errorf("%s: Position = %v, want %v", tt.name, got.Position, tt.wantPosition)
+		}
+		if got.HasAltitude != tt.hasAltitude || got.Altitude != tt.wantAltitude {
+			t.Errorf("%s: Altitude = %f (%v), want %f (%v)", tt.name, got.Altitude, got.HasAltitude, tt.wantAltitude, tt.hasAltitude)
+		}
+		if tt.hasPosition && got.PositionSource != GPSSourceExif {
+			t.Errorf("%s: PositionSource = %q, want %q", tt.name, got.PositionSource, GPSSourceExif)
+		}
+	}
+}
+
+func TestGPSDataValid(t *testing.T) {
+	tests := []struct {
+		name string
+		gps  GPSData
+		want bool
+	}{
+		{name: "empty", gps: GPSData{}, want: false},
+		{name: "has position", gps: GPSData{HasPosition: true}, want: true},
+		{name: "written before HasPosition", gps: GPSData{Position: GPSCoord{39.567961, 2.644539}}, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.gps.Valid(); got != tt.want {
+			t.Errorf("%s: Valid() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
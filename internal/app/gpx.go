@@ -0,0 +1,202 @@
+package app
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/bradfitz/latlong"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GPXPoint is a point of a GPS track log.
+type GPXPoint struct {
+	Time         time.Time
+	Coords       GPSCoord
+	Elevation    float64
+	HasElevation bool
+	Source       string // name of the GPX file
+}
+
+// GPXTrack are the points of one or more GPS track logs, sorted by time.
+type GPXTrack []GPXPoint
+
+type gpxFile struct {
+	Points []struct {
+		Lat  float64  `xml:"lat,attr"`
+		Lon  float64  `xml:"lon,attr"`
+		Ele  *float64 `xml:"ele"`
+		Time string   `xml:"time"`
+	} `xml:"trk>trkseg>trkpt"`
+}
+
+// LoadGPXFiles reads the track points of the GPX files, merged in a single track. Points without time are ignored.
+func LoadGPXFiles(paths []string) (GPXTrack, error) {
+	var track GPXTrack
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if IsError(err) {
+			return nil, err
+		}
+
+		var gpx gpxFile
+		if err := xml.Unmarshal(data, &gpx); IsError(err) {
+			return nil, fmt.Errorf("Cannot parse the GPX file %s: %s", path, err)
+		}
+
+		for _, point := range gpx.Points {
+			t, err := time.Parse(time.RFC3339, point.Time)
+			if IsError(err) || !gpsIsValid(GPSCoord{point.Lat, point.Lon}) {
+				continue
+			}
+
+			trackPoint := GPXPoint{Time: t, Coords: GPSCoord{point.Lat, point.Lon}, Source: filepath.Base(path)}
+			if point.Ele != nil {
+				trackPoint.Elevation = *point.Ele
+				trackPoint.HasElevation = true
+			}
+			track = append(track, trackPoint)
+		}
+	}
+
+	sort.SliceStable(track, func(i, j int) bool { return track[i].Time.Before(track[j].Time) })
+
+	return track, nil
+}
+
+// Locate returns the position at the given time. When the closest points before and after it are both within
+// maxGap, the position is interpolated between them. Otherwise, the closest point within maxGap is used.
+func (track GPXTrack) Locate(t time.Time, maxGap time.Duration) (GPXPoint, bool) {
+	next := sort.Search(len(track), func(i int) bool { return !track[i].Time.Before(t) })
+
+	var before, after *GPXPoint
+	if next > 0 && t.Sub(track[next-1].Time) <= maxGap {
+		before = &track[next-1]
+	}
+	if next < len(track) && track[next].Time.Sub(t) <= maxGap {
+		after = &track[next]
+	}
+
+	switch {
+	case before != nil && after != nil:
+		return interpolateGPXPoints(*before, *after, t), true
+	case before != nil:
+		return *before, true
+	case after != nil:
+		return *after, true
+	}
+
+	return GPXPoint{}, false
+}
+
+func interpolateGPXPoints(a GPXPoint, b GPXPoint, t time.Time) GPXPoint {
+	span := b.Time.Sub(a.Time)
+	if span <= 0 {
+		return b
+	}
+
+	ratio := float64(t.Sub(a.Time)) / float64(span)
+	lerp := func(x, y float64) float64 { return x + (y-x)*ratio }
+
+	point := GPXPoint{
+		Time:         t,
+		Coords:       GPSCoord{lerp(a.Coords.Latitude, b.Coords.Latitude), lerp(a.Coords.Longitude, b.Coords.Longitude)},
+		HasElevation: a.HasElevation && b.HasElevation,
+		Source:       b.Source,
+	}
+	if math.Abs(b.Coords.Longitude-a.Coords.Longitude) > 180 {
+		// crossing the antimeridian, use the closest point instead
+		point.Coords = b.Coords
+		if ratio < 0.5 {
+			point.Coords = a.Coords
+		}
+	}
+	if point.HasElevation {
+		point.Elevation = lerp(a.Elevation, b.Elevation)
+	}
+
+	return point
+}
+
+// geotagFromTrack sets the GPS position of the file from the track logs, at the given creation time. When the
+// timezone of the camera clock is unknown, the track around that time is used to guess it.
+func geotagFromTrack(params CmdOptions, data *FileMeta, creationTime time.Time) bool {
+	maxGap, err := time.ParseDuration(params.GPXMaxGap)
+	if IsError(err) {
+		return false
+	}
+
+	point, found := params.GPXTrack.Locate(creationTime, maxGap)
+	if !found && data.GPS.TimezoneSource == TimezoneSourceDefault {
+		wallClock := time.Date(creationTime.Year(), creationTime.Month(), creationTime.Day(), creationTime.Hour(),
+			creationTime.Minute(), creationTime.Second(), creationTime.Nanosecond(), time.UTC)
+
+		// UTC offsets go from -12h to +14h
+		if guess, ok := params.GPXTrack.Locate(wallClock, 14*time.Hour); ok {
+			loc, err := LoadTimezone(latlong.LookupZoneName(guess.Coords.Latitude, guess.Coords.Longitude))
+			if !IsError(err) {
+				_, offset := wallClock.In(loc).Zone()
+				point, found = params.GPXTrack.Locate(wallClock.Add(-time.Duration(offset)*time.Second), maxGap)
+			}
+		}
+	}
+
+	if !found {
+		return false
+	}
+
+	setTrackPosition(data, point)
+
+	// The position can change the timezone, and so the creation time, so it's located again with the new time
+	candidate := *data
+	if newTime, _, _ := resolveFileDate(params, &candidate); !newTime.Equal(creationTime) {
+		if point, found := params.GPXTrack.Locate(newTime, maxGap); found {
+			setTrackPosition(data, point)
+		}
+	}
+
+	return true
+}
+
+func setTrackPosition(data *FileMeta, point GPXPoint) {
+	data.GPS = GPSDataFromCoords(point.Coords)
+	data.GPS.Altitude = point.Elevation
+	data.GPS.HasAltitude = point.HasElevation
+	data.GPS.PositionSource = GPSSourceGPX + ":" + point.Source
+}
+
+// WriteGPSTags writes the GPS position of the file into the given file with exiftool.
+func WriteGPSTags(path string, gps GPSData) error {
+	args := []string{
+		"-overwrite_original",
+		fmt.Sprintf("-GPSLatitude=%f", math.Abs(gps.Position.Latitude)),
+		fmt.Sprintf("-GPSLatitudeRef=%s", map[bool]string{true: "N", false: "S"}[gps.Position.Latitude >= 0]),
+		fmt.Sprintf("-GPSLongitude=%f", math.Abs(gps.Position.Longitude)),
+		fmt.Sprintf("-GPSLongitudeRef=%s", map[bool]string{true: "E", false: "W"}[gps.Position.Longitude >= 0]),
+	}
+	if gps.HasAltitude {
+		args = append(args,
+			fmt.Sprintf("-GPSAltitude=%f", math.Abs(gps.Altitude)),
+			fmt.Sprintf("-GPSAltitudeRef=%d", map[bool]int{true: 0, false: 1}[gps.Altitude >= 0]))
+	}
+
+	out, err := exec.Command("exiftool", append(args, path)...).CombinedOutput()
+	if IsError(err) {
+		return fmt.Errorf("Cannot write the GPS tags of %s: %s %s", path, err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+func validateGPXOptions(params CmdOptions) error {
+	if _, err := time.ParseDuration(params.GPXMaxGap); IsError(err) {
+		return fmt.Errorf("Invalid GPX max gap %q: %s", params.GPXMaxGap, err)
+	}
+
+	return nil
+}
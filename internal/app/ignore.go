@@ -0,0 +1,143 @@
+package app
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignorePattern is a gitignore-style pattern, relative to the directory it was defined in.
+type ignorePattern struct {
+	Pattern string
+	regex   *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// parseIgnorePattern converts a gitignore-style pattern into a regex. It supports "*", "?", "**", character
+// classes, "!" for negation, a trailing "/" to only match directories and a leading "/" to anchor it to its
+// base directory. Patterns without any "/" match the file or directory name at any depth.
+// Matching is case-insensitive, since media files often come with upper-cased extensions.
+func parseIgnorePattern(pattern string) (ignorePattern, bool) {
+	p := ignorePattern{Pattern: pattern}
+	pattern = strings.TrimSpace(pattern)
+
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return p, false
+	}
+
+	if strings.HasPrefix(pattern, "!") {
+		p.negate = true
+		pattern = pattern[1:]
+	}
+
+	if strings.HasSuffix(pattern, "/") {
+		p.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	if pattern == "" {
+		return p, false
+	}
+
+	var re strings.Builder
+	re.WriteString("(?i)")
+	if anchored {
+		re.WriteString("^")
+	} else {
+		re.WriteString("(^|/)")
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			re.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				re.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			class := strings.Replace(pattern[i+1:i+end], "\\", "\\\\", -1)
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + class + "]")
+			i += end
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+
+	regex, err := regexp.Compile(re.String())
+	if IsError(err) {
+		return p, false
+	}
+	p.regex = regex
+
+	return p, true
+}
+
+func parseIgnorePatterns(patterns []string) []ignorePattern {
+	var parsed []ignorePattern
+
+	for _, pattern := range patterns {
+		if p, ok := parseIgnorePattern(pattern); ok {
+			parsed = append(parsed, p)
+		}
+	}
+
+	return parsed
+}
+
+// readIgnoreFile reads the patterns of an ignore file, one per line. Empty lines and lines starting with "#"
+// are ignored.
+func readIgnoreFile(path string) ([]ignorePattern, error) {
+	f, err := os.Open(path)
+	if IsError(err) {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	return parseIgnorePatterns(lines), scanner.Err()
+}
+
+// matchIgnorePatterns checks a path relative to the base directory of the patterns. The last matching pattern
+// wins, so negated patterns can re-include previously excluded paths. The second returned value tells whether
+// any pattern matched at all.
+func matchIgnorePatterns(patterns []ignorePattern, relPath string, isDir bool) (bool, bool) {
+	relPath = filepath.ToSlash(relPath)
+	excluded, matched := false, false
+
+	for _, p := range patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.regex.MatchString(relPath) {
+			excluded, matched = !p.negate, true
+		}
+	}
+
+	return excluded, matched
+}
@@ -0,0 +1,76 @@
+package app
+
+import "testing"
+
+func TestParseIgnorePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		wantOK  bool
+		negate  bool
+		dirOnly bool
+	}{
+		{pattern: "", wantOK: false},
+		{pattern: "   ", wantOK: false},
+		{pattern: "# comment", wantOK: false},
+		{pattern: "/", wantOK: false},
+		{pattern: "!", wantOK: false},
+		{pattern: "*.tmp", wantOK: true},
+		{pattern: "!keep.tmp", wantOK: true, negate: true},
+		{pattern: "@eaDir/", wantOK: true, dirOnly: true},
+		{pattern: "!cache/", wantOK: true, negate: true, dirOnly: true},
+	}
+
+	for _, tt := range tests {
+		p, ok := parseIgnorePattern(tt.pattern)
+		if ok != tt.wantOK {
+			t.Errorf("parseIgnorePattern(%q) ok = %v, want %v", tt.pattern, ok, tt.wantOK)
+			continue
+		}
+		if ok && (p.negate != tt.negate || p.dirOnly != tt.dirOnly) {
+			t.Errorf("parseIgnorePattern(%q) negate = %v, dirOnly = %v, want %v, %v",
+				tt.pattern, p.negate, p.dirOnly, tt.negate, tt.dirOnly)
+		}
+	}
+}
+
+func TestMatchIgnorePatterns(t *testing.T) {
+	tests := []struct {
+		name        string
+		patterns    []string
+		relPath     string
+		isDir       bool
+		wantExclude bool
+		wantMatch   bool
+	}{
+		{name: "no patterns", relPath: "a/b.jpg"},
+		{name: "name at any depth", patterns: []string{"Thumbs.db"}, relPath: "a/b/Thumbs.db", wantExclude: true, wantMatch: true},
+		{name: "name at root", patterns: []string{"Thumbs.db"}, relPath: "Thumbs.db", wantExclude: true, wantMatch: true},
+		{name: "partial name", patterns: []string{"Thumbs.db"}, relPath: "a/MyThumbs.db"},
+		{name: "wildcard", patterns: []string{"*.tmp"}, relPath: "a/b/file.tmp", wantExclude: true, wantMatch: true},
+		{name: "wildcard does not cross dirs", patterns: []string{"a*.jpg"}, relPath: "a/b.jpg"},
+		{name: "question mark", patterns: []string{"img?.jpg"}, relPath: "img1.jpg", wantExclude: true, wantMatch: true},
+		{name: "character class", patterns: []string{"img[0-4].jpg"}, relPath: "img5.jpg"},
+		{name: "negated class", patterns: []string{"img[!0-4].jpg"}, relPath: "img5.jpg", wantExclude: true, wantMatch: true},
+		{name: "case insensitive", patterns: []string{"*.jpg"}, relPath: "DCIM/IMG_0001.JPG", wantExclude: true, wantMatch: true},
+		{name: "case insensitive dir", patterns: []string{"@EADIR/"}, relPath: "photos/@eaDir", isDir: true, wantExclude: true, wantMatch: true},
+		{name: "anchored", patterns: []string{"/raw"}, relPath: "raw", isDir: true, wantExclude: true, wantMatch: true},
+		{name: "anchored not nested", patterns: []string{"/raw"}, relPath: "2020/raw", isDir: true},
+		{name: "middle slash anchors", patterns: []string{"DCIM/*.mov"}, relPath: "DCIM/clip.MOV", wantExclude: true, wantMatch: true},
+		{name: "middle slash not nested", patterns: []string{"DCIM/*.mov"}, relPath: "card/DCIM/clip.mov"},
+		{name: "double star", patterns: []string{"**/cache/**"}, relPath: "a/b/cache/c/d.jpg", wantExclude: true, wantMatch: true},
+		{name: "double star at root", patterns: []string{"**/cache"}, relPath: "cache", isDir: true, wantExclude: true, wantMatch: true},
+		{name: "dir only matches dir", patterns: []string{"@eaDir/"}, relPath: "a/@eaDir", isDir: true, wantExclude: true, wantMatch: true},
+		{name: "dir only skips file", patterns: []string{"@eaDir/"}, relPath: "a/@eaDir"},
+		{name: "negation", patterns: []string{"*.tmp", "!keep.tmp"}, relPath: "a/keep.tmp", wantExclude: false, wantMatch: true},
+		{name: "negation of other file", patterns: []string{"*.tmp", "!keep.tmp"}, relPath: "a/drop.tmp", wantExclude: true, wantMatch: true},
+		{name: "last pattern wins", patterns: []string{"!keep.tmp", "*.tmp"}, relPath: "keep.tmp", wantExclude: true, wantMatch: true},
+	}
+
+	for _, tt := range tests {
+		excluded, matched := matchIgnorePatterns(parseIgnorePatterns(tt.patterns), tt.relPath, tt.isDir)
+		if excluded != tt.wantExclude || matched != tt.wantMatch {
+			t.Errorf("%s: matchIgnorePatterns(%q, %q) = %v, %v, want %v, %v",
+				tt.name, tt.patterns, tt.relPath, excluded, matched, tt.wantExclude, tt.wantMatch)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math/bits"
+	"os"
+)
+
+// ImageHash is the difference hash (dHash) of an image: every bit tells if a pixel is brighter than the next one,
+// in a 9x8 grayscale thumbnail. Resized, recompressed or slightly edited copies of an image have similar hashes.
+type ImageHash uint64
+
+// CalcImageHash calculates the difference hash of a JPEG, PNG or GIF image.
+func CalcImageHash(path string) (ImageHash, error) {
+	f, err := os.Open(path)
+	if IsError(err) {
+		return 0, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if IsError(err) {
+		return 0, err
+	}
+
+	const width, height = 9, 8
+	var gray [height][width]float64
+	bounds := img.Bounds()
+
+	// average of every cell of the grid, sampling a few pixels of each one to be fast with big images
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := bounds.Min.X + (x+1)*bounds.Dx()/width
+			y0 := bounds.Min.Y + y*bounds.Dy()/height
+			y1 := bounds.Min.Y + (y+1)*bounds.Dy()/height
+			stepX, stepY := max(1, (x1-x0)/8), max(1, (y1-y0)/8)
+
+			var sum float64
+			var count int
+			for py := y0; py < max(y1, y0+1); py += stepY {
+				for px := x0; px < max(x1, x0+1); px += stepX {
+					r, g, b, _ := img.At(px, py).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+					count++
+				}
+			}
+			gray[y][x] = sum / float64(count)
+		}
+	}
+
+	var hash ImageHash
+	for y := 0; y < height; y++ {
+		for x := 0; x < width-1; x++ {
+			hash <<= 1
+			if gray[y][x] > gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash, nil
+}
+
+// Distance is the number of different bits between two hashes. Near-identical images have a distance of 0 to ~5.
+func (h ImageHash) Distance(other ImageHash) int {
+	return bits.OnesCount64(uint64(h ^ other))
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// regexSidecarPath matches the path of the metadata files relative to the metadata directory, e.g.
+// "dc/1/dc1a15b6e39467fd9a4e90c1f3eec947.jpg.json"
+var regexSidecarPath = regexp.MustCompile(`^([0-9a-f]{2})/([0-9a-f])/([0-9a-f]{32})(\.[0-9a-z]+)?\.json$`)
+
+// WalkLibrary calls walkFn with the path and the metadata of every file imported into the library, read from the
+// JSON files of its metadata directory. Other files, and the unreadable metadata or the one without checksum or
+// destination, are skipped.
+func WalkLibrary(params CmdOptions, libraryDir string, walkFn func(metaPath string, file FileMeta) error) error {
+	metadataDir := filepath.Join(libraryDir, params.DirMetadata)
+	if !IsDir(metadataDir) {
+		return nil
+	}
+
+	return filepath.Walk(metadataDir, func(path string, info os.FileInfo, err error) error {
+		if IsError(err) {
+			return err
+		}
+		if info.IsDir() || !isSidecarPath(metadataDir, path) {
+			return nil
+		}
+
+		file, err := ReadFileMeta(path)
+		if IsError(err) {
+			Logger.Warn("unreadable metadata file skipped", "path", path, "error", err)
+			return nil
+		}
+		if file.Checksum == "" || file.Destination.Path == "" {
+			Logger.Warn("invalid metadata file skipped", "path", path)
+			return nil
+		}
+
+		return walkFn(path, file)
+	})
+}
+
+// isSidecarPath tells if a path of the metadata directory is the metadata file of an imported file.
+func isSidecarPath(metadataDir string, path string) bool {
+	relPath, err := filepath.Rel(metadataDir, path)
+	if IsError(err) {
+		return false
+	}
+
+	match := regexSidecarPath.FindStringSubmatch(filepath.ToSlash(relPath))
+
+	return match != nil && match[3][0:2] == match[1] && match[3][2:3] == match[2]
+}
+
+// ReadFileMeta reads a metadata JSON file of the library.
+func ReadFileMeta(path string) (FileMeta, error) {
+	var file FileMeta
+
+	data, err := ioutil.ReadFile(path)
+	if IsError(err) {
+		return file, err
+	}
+
+	return file, json.Unmarshal(data, &file)
+}
+
+// RelativePath returns the path of the file relative to the library directory.
+func (f FileMeta) RelativePath() string {
+	return f.Destination.Dirname + "/" + f.Destination.Basename + f.Destination.Extension
+}
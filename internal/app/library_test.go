@@ -0,0 +1,40 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWalkLibrarySkipsInvalidSidecars(t *testing.T) {
+	params := DefaultCmdOptions()
+	libraryDir := t.TempDir()
+
+	sidecars := map[string]string{
+		"dc/1/dc1a15b6e39467fd9a4e90c1f3eec947.jpg.json": `{"Checksum": "dc1a15b6e39467fd9a4e90c1f3eec947", "Destination": {"Path": "/library/a.jpg"}}`,
+		"9d/9/9d90ac65d0c7010b1e9aac0a0ce3a0f4.jpg.json": `{"Checksum": "9d90ac65d0c7`, // truncated
+		"7f/e/7fe1c74433ef6e19881ea386d440be2f.jpg.json": `{"Checksum": ""}`,
+		"cards.json": `{}`,
+	}
+	for relPath, content := range sidecars {
+		path := filepath.Join(libraryDir, params.DirMetadata, relPath)
+		if err := os.MkdirAll(filepath.Dir(path), DirPerms); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), FilePerms); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var checksums []string
+	err := WalkLibrary(params, libraryDir, func(metaPath string, file FileMeta) error {
+		checksums = append(checksums, file.Checksum)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkLibrary() error = %v", err)
+	}
+	if len(checksums) != 1 || checksums[0] != "dc1a15b6e39467fd9a4e90c1f3eec947" {
+		t.Errorf("WalkLibrary() walked %v, want only the valid metadata file", checksums)
+	}
+}
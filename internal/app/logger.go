@@ -0,0 +1,112 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Logger is the log of the decisions taken for every file. By default, only the warnings and errors are printed.
+var Logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+// SetupLogger configures the Logger by the verbosity (-v prints the decisions taken for every file, -vv also the
+// scanned files and directories) and the log file, which always gets everything. The returned closer must be
+// called at the end, to flush the log file.
+func SetupLogger(params CmdOptions) (io.Closer, error) {
+	consoleLevel := slog.LevelWarn
+	switch {
+	case params.Quiet:
+		consoleLevel = slog.LevelError
+	case params.Verbosity == 1:
+		consoleLevel = slog.LevelInfo
+	case params.Verbosity > 1:
+		consoleLevel = slog.LevelDebug
+	}
+
+	handlers := []slog.Handler{newLogHandler(params.LogFormat, os.Stderr, consoleLevel)}
+	closer := io.Closer(nopCloser{})
+
+	if params.LogFile != "" {
+		f, err := os.OpenFile(params.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, FilePerms)
+		if IsError(err) {
+			return closer, err
+		}
+		handlers = append(handlers, newLogHandler(params.LogFormat, f, slog.LevelDebug))
+		closer = f
+	}
+
+	Logger = slog.New(multiLogHandler(handlers))
+
+	return closer, nil
+}
+
+func newLogHandler(format string, w io.Writer, level slog.Level) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == LogFormatJSON {
+		return slog.NewJSONHandler(w, opts)
+	}
+
+	return slog.NewTextHandler(w, opts)
+}
+
+func validateLogFormat(format string) error {
+	if format != LogFormatText && format != LogFormatJSON {
+		return fmt.Errorf("Unknown log format %q, the supported ones are: text, json.", format)
+	}
+
+	return nil
+}
+
+// multiLogHandler sends the log records to all the handlers enabled for their level.
+type multiLogHandler []slog.Handler
+
+func (h multiLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h multiLogHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, handler := range h {
+		if handler.Enabled(ctx, record.Level) {
+			errs = append(errs, handler.Handle(ctx, record.Clone()))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (h multiLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(multiLogHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+
+	return handlers
+}
+
+func (h multiLogHandler) WithGroup(name string) slog.Handler {
+	handlers := make(multiLogHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithGroup(name)
+	}
+
+	return handlers
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,178 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// MergeLibrary imports the files of the source library into the destination one, using the metadata of the
+// source library instead of reading the files again. The files are organized like in a regular import, with the
+// destination options, and the ones with the same checksum are skipped, completing the metadata of the existing
+// file with the one of the duplicate.
+func MergeLibrary(ctx context.Context, params CmdOptions) (CmdFileStats, error) {
+	stats := newCmdFileStats()
+
+	// metadata file of every checksum of the destination library
+	checksums := map[string]string{}
+	err := WalkLibrary(params, params.DestDir, func(metaPath string, file FileMeta) error {
+		checksums[file.Checksum] = metaPath
+		return nil
+	})
+	if IsError(err) {
+		return stats, err
+	}
+
+	err = WalkLibrary(params, params.SrcDir, func(metaPath string, file FileMeta) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		path := filepath.Join(params.SrcDir, file.RelativePath())
+		if !PathExists(path) {
+			skipFile(&stats, path, SkipReasonMissing)
+			return nil
+		}
+		stats.ScannedSize += file.Size
+
+		if existingMetaPath, ok := checksums[file.Checksum]; ok {
+			existing, err := mergeSidecars(params, existingMetaPath, file)
+			if IsError(err) {
+				return err
+			}
+			stats.DuplicatedFiles++
+			stats.Duplicates = append(stats.Duplicates, DuplicateRecord{
+				Source:      path,
+				DuplicateOf: existing.Destination.Path,
+				Match:       DuplicateMatchChecksum,
+				Metadata:    existingMetaPath,
+			})
+			skipFile(&stats, path, SkipReasonDuplicate, "duplicate_of", existing.Destination.Path)
+			return nil
+		}
+
+		// the other paths of the file are not imported, only the file itself
+		file.Aliases = nil
+		file.Destination, file.MatchedRule = buildDestination(params, file)
+		file.Destination = uniqueDestination(file.Destination)
+		file.MetadataPath = buildChecksumPath(params, params.DestDir, file.Checksum, file.Source.Extension)
+
+		file, err := mergeFile(ctx, params, path, metaPath, file)
+		if isCancelled(ctx, err) {
+			Logger.Warn("file rolled back", "path", path, "destination", file.Destination.Path)
+			return err
+		}
+		if IsError(err) {
+			stats.FailedFiles++
+			stats.Failures = append(stats.Failures, FailureRecord{Source: path, Error: err.Error()})
+			Logger.Error("file failed", "path", path, "destination", file.Destination.Path, "error", err)
+			return nil
+		}
+
+		checksums[file.Checksum] = file.MetadataPath.Path
+		stats.addProcessed(file)
+		Logger.Info("file merged", "path", path, "destination", file.Destination.Path, "mode", file.ImportMode,
+			"dry_run", params.DryRun)
+
+		return nil
+	})
+
+	if isCancelled(ctx, err) {
+		stats.Interrupted = true
+		return stats, nil
+	}
+
+	return stats, err
+}
+
+// uniqueDestination adds a numeric suffix to the destination file name, if there is already a different file there.
+func uniqueDestination(dest FilePathInfo) FilePathInfo {
+	basename := dest.Basename
+	for i := 2; PathExists(dest.Path); i++ {
+		dest.Basename = fmt.Sprintf("%s-%d", basename, i)
+		dest.Path = filepath.Join(filepath.Dir(dest.Path), dest.Basename+dest.Extension)
+	}
+
+	return dest
+}
+
+// mergeFile puts a file of the source library in the destination one, and writes its metadata there.
+// In move mode, the metadata of the source library is removed too.
+func mergeFile(ctx context.Context, params CmdOptions, path string, metaPath string, file FileMeta) (FileMeta, error) {
+	file.ImportMode = params.Mode
+	if params.DryRun {
+		return file, nil
+	}
+
+	for _, dir := range []string{filepath.Dir(file.Destination.Path), filepath.Dir(file.MetadataPath.Path)} {
+		if err := os.MkdirAll(dir, DirPerms); IsError(err) {
+			return file, err
+		}
+	}
+
+	// the files of a library imported with symlinks are links, the destination gets their target instead, unless
+	// they are moved
+	var err error
+	src := path
+	if params.Mode != ModeMove {
+		if src, err = filepath.EvalSymlinks(path); IsError(err) {
+			return file, err
+		}
+	}
+
+	file.ImportMode, err = TransferFile(ctx, src, file.Destination.Path, params.Mode)
+	if IsError(err) {
+		return file, err
+	}
+
+	meta, err := JsonEncodePretty(file)
+	if IsError(err) {
+		return file, err
+	}
+	if err := ioutil.WriteFile(file.MetadataPath.Path, meta, FilePerms); IsError(err) {
+		return file, err
+	}
+
+	if file.ImportMode == ModeMove {
+		return file, os.Remove(metaPath)
+	}
+
+	return file, nil
+}
+
+// mergeSidecars completes the metadata of an existing file with the one of its duplicate, e.g. with the GPS
+// position or the camera model, when it's missing. It returns the existing metadata.
+func mergeSidecars(params CmdOptions, metaPath string, duplicate FileMeta) (FileMeta, error) {
+	existing, err := ReadFileMeta(metaPath)
+	if IsError(err) {
+		return existing, err
+	}
+
+	changed := false
+	if !existing.GPS.Valid() && duplicate.GPS.Valid() {
+		existing.GPS = duplicate.GPS
+		existing.Location = duplicate.Location
+		changed = true
+	}
+	if existing.CameraModel == "" && duplicate.CameraModel != "" {
+		existing.CameraModel = duplicate.CameraModel
+		changed = true
+	}
+	if existing.Category == "" && duplicate.Category != "" {
+		existing.Category = duplicate.Category
+		changed = true
+	}
+	if !changed || params.DryRun {
+		return existing, nil
+	}
+
+	meta, err := JsonEncodePretty(existing)
+	if IsError(err) {
+		return existing, err
+	}
+	Logger.Info("metadata merged", "path", metaPath)
+
+	return existing, ioutil.WriteFile(metaPath, meta, FilePerms)
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeLibraryResolvesSymlinks(t *testing.T) {
+	originalsDir, srcDir, destDir := t.TempDir(), t.TempDir(), t.TempDir()
+	params := DefaultCmdOptions()
+	params.SrcDir = srcDir
+	params.DestDir = destDir
+
+	// a library imported with symlinks, where the file was also deduplicated with another path of the library
+	original := filepath.Join(originalsDir, "IMG_0001.jpg")
+	if err := os.WriteFile(original, []byte("image"), FilePerms); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(srcDir, "images", "2020", "20200102-030405-dc1a15b6e39467fd9a4e90c1f3eec947.jpg")
+	if err := os.MkdirAll(filepath.Dir(link), DirPerms); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(original, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	file := FileMeta{
+		Checksum:     "dc1a15b6e39467fd9a4e90c1f3eec947",
+		MediaType:    MediaTypeImage,
+		CreationTime: "2020-01-02T03:04:05Z",
+		Size:         5,
+		Source:       FilePathInfo{Path: original, Basename: "IMG_0001", Extension: ".jpg"},
+		Destination: FilePathInfo{
+			Path:      link,
+			Dirname:   "images/2020",
+			Basename:  "20200102-030405-dc1a15b6e39467fd9a4e90c1f3eec947",
+			Extension: ".jpg",
+		},
+		Aliases: []string{"images/2020/copy.jpg"},
+	}
+	metaPath := buildChecksumPath(params, srcDir, file.Checksum, file.Source.Extension).Path
+	meta, err := JsonEncodePretty(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(metaPath), DirPerms); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(metaPath, meta, FilePerms); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := MergeLibrary(context.Background(), params)
+	if err != nil {
+		t.Fatalf("MergeLibrary() error = %v", err)
+	}
+	if len(stats.Failures) > 0 {
+		t.Fatalf("MergeLibrary() failures = %v", stats.Failures)
+	}
+
+	merged, err := ReadFileMeta(buildChecksumPath(params, destDir, file.Checksum, file.Source.Extension).Path)
+	if err != nil {
+		t.Fatalf("ReadFileMeta() error = %v", err)
+	}
+	if len(merged.Aliases) > 0 {
+		t.Errorf("merged aliases = %v, want none", merged.Aliases)
+	}
+
+	info, err := os.Lstat(merged.Destination.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Mode().IsRegular() {
+		t.Errorf("merged file mode = %v, want a regular file", info.Mode())
+	}
+}
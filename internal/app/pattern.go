@@ -0,0 +1,71 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	regexPathPlaceholder = regexp.MustCompile(`\{([a-z_]+)\}`)
+	regexPathUnsafeChars = regexp.MustCompile(`[/\\:*?"<>|]+`)
+)
+
+// pathPlaceholders are the values that can be used in the destination pattern and in the rule and route
+// directories, e.g. "{media}/{country}/{year}/{month}".
+func pathPlaceholders(data FileMeta, t time.Time, mediaTypeDir string) map[string]string {
+	return map[string]string{
+		"media":        mediaTypeDir,
+		"year":         fmt.Sprintf("%d", t.Year()),
+		"month":        fmt.Sprintf("%02d", t.Month()),
+		"day":          fmt.Sprintf("%02d", t.Day()),
+		"country":      data.Location.Country,
+		"country_code": data.Location.CountryCode,
+		"region":       data.Location.Region,
+		"city":         data.Location.City,
+		"camera":       data.CameraModel,
+		"category":     data.Category,
+		"type":         data.MediaType,
+	}
+}
+
+// expandPathPattern replaces the placeholders of a destination pattern. Empty values are replaced with "Unknown",
+// and the ones that could break the path (like slashes) are sanitized.
+func expandPathPattern(pattern string, data FileMeta, t time.Time, mediaTypeDir string) string {
+	values := pathPlaceholders(data, t, mediaTypeDir)
+
+	path := regexPathPlaceholder.ReplaceAllStringFunc(pattern, func(placeholder string) string {
+		name := strings.Trim(placeholder, "{}")
+		value, ok := values[name]
+		if !ok {
+			return placeholder
+		}
+		if name == "media" {
+			return strings.Trim(value, "/")
+		}
+		value = strings.TrimSpace(regexPathUnsafeChars.ReplaceAllString(value, "-"))
+		if value == "" || value == "." || value == ".." {
+			return DefaultCameraModelFallback
+		}
+		return value
+	})
+
+	return strings.Trim(path, "/")
+}
+
+func validatePathPattern(name string, pattern string) error {
+	if pattern == "" || filepath.IsAbs(pattern) || strings.Contains(pattern, "..") {
+		return fmt.Errorf("The %s must be a relative path.", name)
+	}
+
+	values := pathPlaceholders(FileMeta{}, time.Time{}, "")
+	for _, match := range regexPathPlaceholder.FindAllStringSubmatch(pattern, -1) {
+		if _, ok := values[match[1]]; !ok {
+			return fmt.Errorf("Unknown placeholder %s in the %s.", match[0], name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	tm "github.com/buger/goterm"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	progressBarWidth        = 30
+	progressTTYInterval     = 100 * time.Millisecond
+	progressNonTTYInterval  = 10 * time.Second
+	progressMinETAElapsed   = 2 * time.Second
+	progressMinETAFileCount = 5
+)
+
+// Progress renders the progress of a run. On a terminal, it redraws a bar in place. Otherwise, e.g. when the
+// output is piped to a file by cron or Docker, it prints a single line every few seconds.
+type Progress struct {
+	params     CmdOptions
+	out        io.Writer
+	isTTY      bool
+	total      int
+	totalBytes int64
+	lastRender time.Time
+}
+
+// NewProgress creates a progress renderer for the given total number of files and bytes, from CountFiles.
+func NewProgress(params CmdOptions, total int, totalBytes int64) *Progress {
+	return &Progress{
+		params:     params,
+		out:        os.Stdout,
+		isTTY:      IsTerminal(os.Stdout),
+		total:      total,
+		totalBytes: totalBytes,
+	}
+}
+
+// IsTerminal tells if the file is an interactive terminal, instead of a pipe or a regular file.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+
+	return !IsError(err) && info.Mode()&os.ModeCharDevice != 0
+}
+
+// CountFiles walks the source directory to count the files that will be scanned, and their total size.
+func CountFiles(ctx context.Context, params CmdOptions) (int, int64, error) {
+	var count int
+	var size int64
+	filter := newFileFilter(params)
+
+	err := filepath.Walk(params.SrcDir, func(path string, info os.FileInfo, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if IsError(err) {
+			return err
+		}
+
+		if info.IsDir() {
+			if filter.dirSkipReason(path) != "" {
+				return filepath.SkipDir
+			}
+			return filter.loadIgnoreFile(path)
+		}
+
+		count++
+		size += info.Size()
+
+		return nil
+	})
+
+	return count, size, err
+}
+
+// Update renders the progress, unless it was already rendered recently.
+func (p *Progress) Update(stats CmdFileStats) {
+	interval := progressNonTTYInterval
+	if p.isTTY {
+		interval = progressTTYInterval
+	}
+
+	if time.Since(p.lastRender) < interval {
+		return
+	}
+	p.lastRender = time.Now()
+
+	if p.isTTY {
+		fmt.Fprintf(p.out, "\r\033[K%s", p.ttyLine(stats))
+		return
+	}
+
+	fmt.Fprintf(p.out, "[%s] %s\n", AppName, p.line(stats))
+}
+
+// Finish renders the final state of the progress bar.
+func (p *Progress) Finish(stats CmdFileStats) {
+	if p.isTTY {
+		fmt.Fprintf(p.out, "\r\033[K%s\n", p.ttyLine(stats))
+	}
+}
+
+func (p *Progress) ttyLine(stats CmdFileStats) string {
+	done := stats.ProcessedFiles + stats.SkippedFiles + stats.FailedFiles
+	filled := 0
+	if p.total > 0 {
+		filled = progressBarWidth * min(done, p.total) / p.total
+	}
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+
+	return fmt.Sprintf("[%s] %s", bar, tm.Color(tm.Bold(p.line(stats)), tm.YELLOW))
+}
+
+func (p *Progress) line(stats CmdFileStats) string {
+	done := stats.ProcessedFiles + stats.SkippedFiles + stats.FailedFiles
+	elapsed := p.elapsed().Seconds()
+
+	var percent, filesPerSec, bytesPerSec float64
+	if p.total > 0 {
+		percent = 100 * float64(min(done, p.total)) / float64(p.total)
+	}
+	if elapsed > 0 {
+		filesPerSec = float64(done) / elapsed
+		bytesPerSec = float64(stats.ScannedSize) / elapsed
+	}
+
+	return fmt.Sprintf("%3.0f%% %d/%d files, %.1f files/s, %s/s, %d duplicates, ETA %s",
+		percent,
+		done,
+		p.total,
+		filesPerSec,
+		TotalBytesToString(int64(bytesPerSec), false),
+		stats.DuplicatedFiles,
+		p.eta(stats),
+	)
+}
+
+// eta estimates the remaining time by the bytes still to be scanned, which is more accurate than the number of files
+// when there is a mix of photos and videos.
+func (p *Progress) eta(stats CmdFileStats) string {
+	done := stats.ProcessedFiles + stats.SkippedFiles + stats.FailedFiles
+	elapsed := p.elapsed()
+
+	if elapsed < progressMinETAElapsed || done < progressMinETAFileCount || done >= p.total {
+		return "--"
+	}
+
+	remaining := float64(p.total-done) / float64(done)
+	if p.totalBytes > 0 && stats.ScannedSize > 0 {
+		remaining = float64(p.totalBytes-stats.ScannedSize) / float64(stats.ScannedSize)
+	}
+
+	return (time.Duration(remaining * float64(elapsed))).Round(time.Second).String()
+}
+
+func (p *Progress) elapsed() time.Duration {
+	return time.Since(p.params.CurrentTime)
+}
@@ -0,0 +1,185 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// PairCache keeps what is known about the RAW+JPEG (or RAW+HEIC) pairs during a run: the files of every directory
+// by basename, and the metadata of the pair files. The metadata of the other half of a pair is read once, when
+// looking for the pair, and reused when that file is processed. The files are forgotten once both halves of their
+// pair are processed.
+type PairCache struct {
+	filter *fileFilter
+	dirs   map[string]map[string][]string // file names by directory and lower-cased basename
+	files  map[string]pairFile
+}
+
+type pairFile struct {
+	Size      int64
+	ModTime   time.Time
+	Meta      FileMeta
+	Processed bool // the file was already processed, not only read when looking for its pair
+}
+
+func NewPairCache() *PairCache {
+	return &PairCache{dirs: map[string]map[string][]string{}, files: map[string]pairFile{}}
+}
+
+// useFilter makes the pair candidates pass the same filters as the files of the walk.
+func (c *PairCache) useFilter(filter *fileFilter) {
+	if c != nil {
+		c.filter = filter
+	}
+}
+
+// siblings returns the names of the other files of the directory with the given basename. The directory is only
+// read again when the file is not known yet, e.g. when it was added while watching the source directory.
+func (c *PairCache) siblings(dir string, fileName string) []string {
+	baseName := strings.ToLower(strings.TrimSuffix(fileName, filepath.Ext(fileName)))
+
+	byBaseName, ok := c.dirs[dir]
+	if !ok || !slices.Contains(byBaseName[baseName], fileName) {
+		entries, err := os.ReadDir(dir)
+		if IsError(err) {
+			return nil
+		}
+		byBaseName = map[string][]string{}
+		for _, entry := range entries {
+			if name := entry.Name(); !entry.IsDir() && isPairCandidate(name) {
+				key := strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+				byBaseName[key] = append(byBaseName[key], name)
+			}
+		}
+		c.dirs[dir] = byBaseName
+	}
+
+	var siblings []string
+	for _, name := range byBaseName[baseName] {
+		if name != fileName {
+			siblings = append(siblings, name)
+		}
+	}
+
+	return siblings
+}
+
+// take returns the metadata of a file that was read when looking for its pair, if it didn't change since then.
+func (c *PairCache) take(path string, info os.FileInfo) (FileMeta, bool) {
+	if c == nil {
+		return FileMeta{}, false
+	}
+
+	file, ok := c.files[path]
+	if !ok || file.Processed || file.Size != info.Size() || !file.ModTime.Equal(info.ModTime()) {
+		return FileMeta{}, false
+	}
+	delete(c.files, path)
+
+	return file.Meta, true
+}
+
+// rememberFile keeps the metadata of a processed file while the other half of its pair is not processed yet, for
+// it to be checked against it. Once both halves are processed, they are forgotten.
+func (c *PairCache) rememberFile(info os.FileInfo, file FileMeta) {
+	if c == nil {
+		return
+	}
+
+	pending := false
+	for _, path := range c.candidates(file) {
+		if pair, ok := c.files[path]; ok && pair.Processed {
+			delete(c.files, path)
+		} else if ok {
+			pending = true
+		}
+	}
+	if !pending {
+		return
+	}
+
+	// the raw metadata is not needed anymore
+	file.Exif = ExifData{}
+	c.files[file.Source.Path] = pairFile{Size: info.Size(), ModTime: info.ModTime(), Meta: file, Processed: true}
+}
+
+// candidates returns the paths of the files that could be the other half of a pair: the ones in the same directory
+// with the same basename and the other kind of extension, since a RAW can only be paired with a JPEG/HEIC and
+// vice versa.
+func (c *PairCache) candidates(file FileMeta) []string {
+	isPairable := regexp.MustCompile(RegexImagePaired).MatchString(file.Source.Extension)
+	if !file.IsRaw && !isPairable {
+		return nil
+	}
+
+	var paths []string
+	for _, name := range c.siblings(file.Source.Dirname, filepath.Base(file.Source.Path)) {
+		if file.IsRaw && regexp.MustCompile(RegexImagePaired).MatchString(name) ||
+			!file.IsRaw && regexp.MustCompile(RegexImageRaw).MatchString(name) {
+			paths = append(paths, filepath.Join(file.Source.Dirname, name))
+		}
+	}
+
+	return paths
+}
+
+// pairMeta returns the metadata of the other half of a pair, reading it if it was not processed yet.
+func (c *PairCache) pairMeta(ctx context.Context, params CmdOptions, path string, info os.FileInfo) (FileMeta, bool) {
+	if file, ok := c.files[path]; ok && file.Size == info.Size() && file.ModTime.Equal(info.ModTime()) {
+		return file.Meta, true
+	}
+
+	file, err := readFileMeta(ctx, params, path, info)
+	if IsError(err) {
+		return file, false
+	}
+	c.files[path] = pairFile{Size: info.Size(), ModTime: info.ModTime(), Meta: file}
+
+	return file, true
+}
+
+func isPairCandidate(path string) bool {
+	return regexp.MustCompile(RegexImageRaw).MatchString(path) || regexp.MustCompile(RegexImagePaired).MatchString(path)
+}
+
+// findPairedFile looks for the other half of a RAW+JPEG (or RAW+HEIC) pair in the same directory. Both files are
+// only considered a pair when they have the same basename, camera and creation time, and the other half is not
+// filtered out. It returns the path of the paired file and the checksum both files should share in their
+// destination name, which is the one of the RAW file. Pairs are only detected in runs with a PairCache.
+func findPairedFile(ctx context.Context, params CmdOptions, file FileMeta) (string, string) {
+	if params.PairCache == nil {
+		return "", ""
+	}
+
+	for _, pairPath := range params.PairCache.candidates(file) {
+		info, err := os.Lstat(pairPath)
+		if IsError(err) || !info.Mode().IsRegular() {
+			continue
+		}
+		if filter := params.PairCache.filter; filter != nil && filter.fileSkipReason(pairPath, info) != "" {
+			continue
+		}
+
+		pair, ok := params.PairCache.pairMeta(ctx, params, pairPath, info)
+		if !ok || pair.MediaType == "" || dateSkipReason(params, pair) != "" {
+			continue
+		}
+
+		if pair.CameraModel != file.CameraModel || pair.CreationTime != file.CreationTime {
+			continue
+		}
+
+		if file.IsRaw {
+			return pairPath, file.Checksum
+		}
+
+		return pairPath, pair.Checksum
+	}
+
+	return "", ""
+}
@@ -0,0 +1,80 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFindPairedFile(t *testing.T) {
+	srcDir := t.TempDir()
+	shotTime := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	files := map[string]time.Time{
+		"IMG_0001.JPG": shotTime, "IMG_0001.ORF": shotTime, // the JPEG is walked first
+		"IMG_0002.CR2": shotTime, "IMG_0002.JPG": shotTime, // the RAW is walked first
+		"IMG_0003.JPG": shotTime, "IMG_0003.NEF": shotTime.Add(time.Hour), // taken at different times
+		"IMG_0004.JPG": shotTime, "IMG_0004.NEF": shotTime, // the RAW is excluded
+		"IMG_0005.JPG": shotTime,
+	}
+	for name, modTime := range files {
+		path := filepath.Join(srcDir, name)
+		if err := os.WriteFile(path, []byte(name), FilePerms); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	params := DefaultCmdOptions()
+	params.SrcDir, params.DestDir = srcDir, t.TempDir()
+	params.MinFileSize = 0
+	params.Exclude = []string{"IMG_0004.NEF"}
+	params.PairCache = NewPairCache()
+	params.PairCache.useFilter(newFileFilter(params))
+
+	checksums := map[string]string{}
+	pairs := map[string]string{}
+	for _, name := range []string{"IMG_0001.JPG", "IMG_0001.ORF", "IMG_0002.CR2", "IMG_0002.JPG", "IMG_0003.JPG",
+		"IMG_0003.NEF", "IMG_0004.JPG", "IMG_0005.JPG"} {
+		path := filepath.Join(srcDir, name)
+		info, err := os.Lstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, err := GetFileMetadata(context.Background(), params, path, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checksums[name] = file.Checksum
+		pairs[name] = file.PairChecksum
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "IMG_0001.JPG", want: checksums["IMG_0001.ORF"]},
+		{name: "IMG_0001.ORF", want: checksums["IMG_0001.ORF"]},
+		{name: "IMG_0002.CR2", want: checksums["IMG_0002.CR2"]},
+		{name: "IMG_0002.JPG", want: checksums["IMG_0002.CR2"]},
+		{name: "IMG_0003.JPG", want: ""},
+		{name: "IMG_0003.NEF", want: ""},
+		{name: "IMG_0004.JPG", want: ""},
+		{name: "IMG_0005.JPG", want: ""},
+	}
+
+	for _, tt := range tests {
+		if pairs[tt.name] != tt.want {
+			t.Errorf("%s: PairChecksum = %q, want %q", tt.name, pairs[tt.name], tt.want)
+		}
+	}
+
+	// the metadata of the pairs is forgotten once both halves are processed
+	for path := range params.PairCache.files {
+		t.Errorf("PairCache still has %s", filepath.Base(path))
+	}
+}
@@ -0,0 +1,47 @@
+//go:build linux
+
+package app
+
+import (
+	"os"
+	"syscall"
+)
+
+// ioctl request to clone a file, from linux/fs.h
+const ioctlFICLONE = 0x40049409
+
+// FileReflink creates a copy-on-write clone of the source file, which shares its data blocks until either file
+// is modified.
+func FileReflink(src string, dest string) error {
+	s, err := os.Open(src)
+	if IsError(err) {
+		return err
+	}
+	defer s.Close()
+
+	d, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, FilePerms)
+	if IsError(err) {
+		return err
+	}
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, d.Fd(), ioctlFICLONE, s.Fd()); errno != 0 {
+		d.Close()
+		return &os.PathError{Op: "reflink", Path: dest, Err: errno}
+	}
+
+	if err := d.Close(); IsError(err) {
+		return err
+	}
+
+	info, err := s.Stat()
+	if IsError(err) {
+		return err
+	}
+
+	// keep the attributes, like a copy with cp -p
+	if err := os.Chmod(dest, info.Mode()); IsError(err) {
+		return err
+	}
+
+	return os.Chtimes(dest, info.ModTime(), info.ModTime())
+}
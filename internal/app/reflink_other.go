@@ -0,0 +1,13 @@
+//go:build !linux
+
+package app
+
+import (
+	"errors"
+	"os"
+)
+
+// FileReflink is only supported on Linux, the other platforms fall back to a copy.
+func FileReflink(src string, dest string) error {
+	return &os.LinkError{Op: "reflink", Old: src, New: dest, Err: errors.ErrUnsupported}
+}
@@ -0,0 +1,312 @@
+package app
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	texttemplate "text/template"
+	"time"
+)
+
+const (
+	ReportFormatText     = "text"
+	ReportFormatJSON     = "json"
+	ReportFormatMarkdown = "markdown"
+	ReportFormatHTML     = "html"
+)
+
+var ReportFormats = []string{ReportFormatText, ReportFormatJSON, ReportFormatMarkdown, ReportFormatHTML}
+
+// RunReport is the summary of a run, with the outcome of the files and a breakdown of the processed ones.
+type RunReport struct {
+	Source          string
+	Destination     string
+	DryRun          bool
+	Interrupted     bool
+	StartTime       time.Time
+	EndTime         time.Time
+	Elapsed         string
+	ProcessedFiles  int
+	SkippedFiles    int
+	DuplicatedFiles int
+	FailedFiles     int
+	CopiedSize      int64
+	ScannedSize     int64
+	SkipReasons     []ReportGroup
+	ByYear          []ReportGroup
+	ByCamera        []ReportGroup
+	ByMediaType     []ReportGroup
+	Duplicates      []DuplicateRecord
+	Failures        []FailureRecord
+}
+
+type ReportGroup struct {
+	Name  string
+	Files int
+	Size  int64
+}
+
+func newCmdFileStats() CmdFileStats {
+	return CmdFileStats{
+		SkipReasons: map[string]int{},
+		ByYear:      map[string]StatsGroup{},
+		ByCamera:    map[string]StatsGroup{},
+		ByMediaType: map[string]StatsGroup{},
+	}
+}
+
+// addProcessed counts a processed file, in the totals and in its year, camera and media type groups.
+func (stats *CmdFileStats) addProcessed(file FileMeta) {
+	stats.ProcessedFiles++
+	stats.TotalSize += file.Size
+
+	year := DefaultCameraModelFallback
+	if t, err := time.Parse(DateFormat, file.CreationTime); !IsError(err) {
+		year = fmt.Sprintf("%d", t.Year())
+	}
+	camera := file.CameraModel
+	if camera == "" {
+		camera = DefaultCameraModelFallback
+	}
+
+	for groups, name := range map[*map[string]StatsGroup]string{
+		&stats.ByYear:      year,
+		&stats.ByCamera:    camera,
+		&stats.ByMediaType: file.MediaType,
+	} {
+		group := (*groups)[name]
+		group.Files++
+		group.Size += file.Size
+		(*groups)[name] = group
+	}
+}
+
+// NewRunReport builds the report of a finished run.
+func NewRunReport(params CmdOptions, stats CmdFileStats) RunReport {
+	endTime := time.Now()
+
+	skipReasons := map[string]StatsGroup{}
+	for reason, count := range stats.SkipReasons {
+		skipReasons[reason] = StatsGroup{Files: count}
+	}
+
+	return RunReport{
+		Source:          params.SrcDir,
+		Destination:     params.DestDir,
+		DryRun:          params.DryRun,
+		Interrupted:     stats.Interrupted,
+		StartTime:       params.CurrentTime,
+		EndTime:         endTime,
+		Elapsed:         endTime.Sub(params.CurrentTime).Round(time.Second).String(),
+		ProcessedFiles:  stats.ProcessedFiles,
+		SkippedFiles:    stats.SkippedFiles,
+		DuplicatedFiles: stats.DuplicatedFiles,
+		FailedFiles:     stats.FailedFiles,
+		CopiedSize:      stats.TotalSize,
+		ScannedSize:     stats.ScannedSize,
+		SkipReasons:     sortedReportGroups(skipReasons, false),
+		ByYear:          sortedReportGroups(stats.ByYear, true),
+		ByCamera:        sortedReportGroups(stats.ByCamera, false),
+		ByMediaType:     sortedReportGroups(stats.ByMediaType, false),
+		Duplicates:      stats.Duplicates,
+		Failures:        stats.Failures,
+	}
+}
+
+// sortedReportGroups sorts the groups by name, or by number of files in descending order.
+func sortedReportGroups(groups map[string]StatsGroup, byName bool) []ReportGroup {
+	sorted := []ReportGroup{}
+	for name, group := range groups {
+		sorted = append(sorted, ReportGroup{Name: name, Files: group.Files, Size: group.Size})
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if byName || sorted[i].Files == sorted[j].Files {
+			return sorted[i].Name < sorted[j].Name
+		}
+		return sorted[i].Files > sorted[j].Files
+	})
+
+	return sorted
+}
+
+// WriteReport writes the report in the given format: text, json, markdown or html.
+func WriteReport(w io.Writer, report RunReport, format string) error {
+	switch format {
+	case ReportFormatText:
+		return writeTextReport(w, report)
+	case ReportFormatJSON:
+		data, err := JsonEncodePretty(report)
+		if IsError(err) {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	case ReportFormatMarkdown:
+		return markdownReportTemplate.Execute(w, report)
+	case ReportFormatHTML:
+		return htmlReportTemplate.Execute(w, report)
+	}
+
+	return fmt.Errorf("Unknown report format %q, the supported ones are: %v.", format, ReportFormats)
+}
+
+func validateReportFormat(format string) error {
+	for _, supported := range ReportFormats {
+		if format == supported {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Unknown report format %q, the supported ones are: %v.", format, ReportFormats)
+}
+
+func writeTextReport(w io.Writer, report RunReport) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	status := map[bool]string{true: "Interrupted after", false: "Done in"}[report.Interrupted]
+	fmt.Fprintf(tw, "[%s] %s %s%s\n\n", AppName, status, report.Elapsed, map[bool]string{true: " (dry run)"}[report.DryRun])
+	fmt.Fprintf(tw, "Source:\t%s\n", report.Source)
+	fmt.Fprintf(tw, "Destination:\t%s\n", report.Destination)
+	fmt.Fprintf(tw, "Processed:\t%d\t%s\n", report.ProcessedFiles, TotalBytesToString(report.CopiedSize, false))
+	fmt.Fprintf(tw, "Skipped:\t%d\n", report.SkippedFiles)
+	fmt.Fprintf(tw, "Duplicates:\t%d\n", report.DuplicatedFiles)
+	fmt.Fprintf(tw, "Failed:\t%d\n", report.FailedFiles)
+
+	for _, section := range []struct {
+		title  string
+		groups []ReportGroup
+	}{
+		{"Skip reasons", report.SkipReasons},
+		{"By year", report.ByYear},
+		{"By camera", report.ByCamera},
+		{"By media type", report.ByMediaType},
+	} {
+		if len(section.groups) == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "\n%s:\n", section.title)
+		for _, group := range section.groups {
+			if group.Size > 0 {
+				fmt.Fprintf(tw, "  %s\t%d\t%s\n", group.Name, group.Files, TotalBytesToString(group.Size, false))
+			} else {
+				fmt.Fprintf(tw, "  %s\t%d\n", group.Name, group.Files)
+			}
+		}
+	}
+
+	if len(report.Duplicates) > 0 {
+		fmt.Fprintf(tw, "\nDuplicates:\n")
+		for _, duplicate := range report.Duplicates {
+			fmt.Fprintf(tw, "  %s\t-> %s\n", duplicate.Source, duplicate.DuplicateOf)
+		}
+	}
+
+	if len(report.Failures) > 0 {
+		fmt.Fprintf(tw, "\nFailures:\n")
+		for _, failure := range report.Failures {
+			fmt.Fprintf(tw, "  %s\t%s\n", failure.Source, failure.Error)
+		}
+	}
+
+	return tw.Flush()
+}
+
+var reportTemplateFuncs = map[string]interface{}{
+	"size": func(size int64) string { return TotalBytesToString(size, false) },
+	"md":   func(value string) string { return strings.NewReplacer("|", "\\|", "\n", " ").Replace(value) },
+}
+
+var markdownReportTemplate = texttemplate.Must(texttemplate.New("markdown").Funcs(reportTemplateFuncs).Parse(`# {{.Source}} -> {{.Destination}}
+{{if .Interrupted}}Interrupted after{{else}}Done in{{end}} {{.Elapsed}}{{if .DryRun}} (dry run){{end}}, from {{.StartTime.Format "2006-01-02 15:04:05"}} to {{.EndTime.Format "2006-01-02 15:04:05"}}.
+
+| Outcome | Files |
+| --- | ---: |
+| Processed | {{.ProcessedFiles}} ({{size .CopiedSize}}) |
+| Skipped | {{.SkippedFiles}} |
+| Duplicates | {{.DuplicatedFiles}} |
+| Failed | {{.FailedFiles}} |
+{{define "groups"}}
+| Name | Files | Size |
+| --- | ---: | ---: |
+{{range .}}| {{md .Name}} | {{.Files}} | {{if .Size}}{{size .Size}}{{end}} |
+{{end}}{{end}}
+{{- if .SkipReasons}}
+## Skip reasons
+{{template "groups" .SkipReasons}}{{end}}
+{{- if .ByYear}}
+## By year
+{{template "groups" .ByYear}}{{end}}
+{{- if .ByCamera}}
+## By camera
+{{template "groups" .ByCamera}}{{end}}
+{{- if .ByMediaType}}
+## By media type
+{{template "groups" .ByMediaType}}{{end}}
+{{- if .Duplicates}}
+## Duplicates
+
+| Source | Duplicate of |
+| --- | --- |
+{{range .Duplicates}}| {{md .Source}} | {{md .DuplicateOf}} |
+{{end}}{{end}}
+{{- if .Failures}}
+## Failures
+
+| Source | Error |
+| --- | --- |
+{{range .Failures}}| {{md .Source}} | {{md .Error}} |
+{{end}}{{end}}`))
+
+var htmlReportTemplate = template.Must(template.New("html").Funcs(reportTemplateFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>mediatidy report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: left; }
+</style>
+</head>
+<body>
+<h1>{{.Source}} &rarr; {{.Destination}}</h1>
+<p>{{if .Interrupted}}Interrupted after{{else}}Done in{{end}} {{.Elapsed}}{{if .DryRun}} (dry run){{end}}, from {{.StartTime.Format "2006-01-02 15:04:05"}} to {{.EndTime.Format "2006-01-02 15:04:05"}}.</p>
+<table>
+<tr><th>Outcome</th><th>Files</th></tr>
+<tr><td>Processed</td><td>{{.ProcessedFiles}} ({{size .CopiedSize}})</td></tr>
+<tr><td>Skipped</td><td>{{.SkippedFiles}}</td></tr>
+<tr><td>Duplicates</td><td>{{.DuplicatedFiles}}</td></tr>
+<tr><td>Failed</td><td>{{.FailedFiles}}</td></tr>
+</table>
+{{define "groups"}}<table>
+<tr><th>Name</th><th>Files</th><th>Size</th></tr>
+{{range .}}<tr><td>{{.Name}}</td><td>{{.Files}}</td><td>{{if .Size}}{{size .Size}}{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+{{- if .SkipReasons}}<h2>Skip reasons</h2>
+{{template "groups" .SkipReasons}}{{end}}
+{{- if .ByYear}}<h2>By year</h2>
+{{template "groups" .ByYear}}{{end}}
+{{- if .ByCamera}}<h2>By camera</h2>
+{{template "groups" .ByCamera}}{{end}}
+{{- if .ByMediaType}}<h2>By media type</h2>
+{{template "groups" .ByMediaType}}{{end}}
+{{- if .Duplicates}}<h2>Duplicates</h2>
+<table>
+<tr><th>Source</th><th>Duplicate of</th></tr>
+{{range .Duplicates}}<tr><td>{{.Source}}</td><td>{{.DuplicateOf}}</td></tr>
+{{end}}</table>
+{{end}}
+{{- if .Failures}}<h2>Failures</h2>
+<table>
+<tr><th>Source</th><th>Error</th></tr>
+{{range .Failures}}<tr><td>{{.Source}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>
+{{end -}}
+</body>
+</html>
+`))
@@ -0,0 +1,159 @@
+package app
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RoutingRule sends the files matching all its conditions to its own destination directory,
+// which replaces the media type directory (e.g. "originals").
+type RoutingRule struct {
+	Name        string          `yaml:"name"`
+	Match       []RuleCondition `yaml:"match"`
+	Destination string          `yaml:"destination"`
+}
+
+// RuleCondition matches a FileMeta field (e.g. "CameraModel", "GPS.Timezone"), an ExifToolData field
+// (e.g. "CreatorTool") or any other tag reported by exiftool. Empty operators are ignored.
+// Min and Max are compared as numbers when possible, or as strings otherwise (e.g. for dates).
+type RuleCondition struct {
+	Field    string  `yaml:"field"`
+	Equals   *string `yaml:"equals,omitempty"`
+	Contains string  `yaml:"contains,omitempty"`
+	Regex    string  `yaml:"regex,omitempty"`
+	Min      string  `yaml:"min,omitempty"`
+	Max      string  `yaml:"max,omitempty"`
+}
+
+// matchRoutingRule returns the first rule, in the configured order, whose conditions all match the file.
+func matchRoutingRule(params CmdOptions, file FileMeta) (RoutingRule, bool) {
+	for _, rule := range params.Rules {
+		matched := true
+		for _, cond := range rule.Match {
+			if !cond.matches(file) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return rule, true
+		}
+	}
+
+	return RoutingRule{}, false
+}
+
+func (cond RuleCondition) matches(file FileMeta) bool {
+	value, found := GetFileMetaField(file, cond.Field)
+	if !found {
+		return false
+	}
+
+	if cond.Equals != nil && !strings.EqualFold(value, *cond.Equals) {
+		return false
+	}
+
+	if cond.Contains != "" && !strings.Contains(strings.ToLower(value), strings.ToLower(cond.Contains)) {
+		return false
+	}
+
+	if cond.Regex != "" && !regexp.MustCompile(cond.Regex).MatchString(value) {
+		return false
+	}
+
+	if cond.Min != "" && compareValues(value, cond.Min) < 0 {
+		return false
+	}
+
+	if cond.Max != "" && compareValues(value, cond.Max) > 0 {
+		return false
+	}
+
+	return true
+}
+
+func compareValues(a string, b string) int {
+	numA, errA := strconv.ParseFloat(a, 64)
+	numB, errB := strconv.ParseFloat(b, 64)
+
+	if !IsError(errA) && !IsError(errB) {
+		switch {
+		case numA < numB:
+			return -1
+		case numA > numB:
+			return 1
+		}
+		return 0
+	}
+
+	return strings.Compare(a, b)
+}
+
+// GetFileMetaField returns the value of a field by its name or dotted path, looking it up in FileMeta,
+// then in the parsed ExifToolData and finally in all the tags reported by exiftool.
+func GetFileMetaField(file FileMeta, field string) (string, bool) {
+	if value, found := getStructField(reflect.ValueOf(file), field); found {
+		return value, true
+	}
+
+	if value, found := getStructField(reflect.ValueOf(file.Exif.Data), field); found {
+		return value, true
+	}
+
+	if _, found := file.Exif.DataDump[field]; found {
+		return GetJsonMapValue(file.Exif.DataDump, field), true
+	}
+
+	return "", false
+}
+
+func getStructField(v reflect.Value, path string) (string, bool) {
+	for _, name := range strings.Split(path, ".") {
+		if v.Kind() != reflect.Struct {
+			return "", false
+		}
+		v = v.FieldByName(name)
+		if !v.IsValid() {
+			return "", false
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Interface, reflect.Ptr:
+		return "", false
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), true
+	}
+
+	return fmt.Sprint(v.Interface()), true
+}
+
+func validateRoutingRules(rules []RoutingRule) error {
+	for i, rule := range rules {
+		if rule.Name == "" {
+			return fmt.Errorf("Rule #%d has no name.", i+1)
+		}
+		if len(rule.Match) == 0 {
+			return fmt.Errorf("Rule %q has no match conditions.", rule.Name)
+		}
+		if err := validatePathPattern(fmt.Sprintf("destination of rule %q", rule.Name), rule.Destination); IsError(err) {
+			return err
+		}
+		for _, cond := range rule.Match {
+			if cond.Field == "" {
+				return fmt.Errorf("Rule %q has a condition without field.", rule.Name)
+			}
+			if cond.Regex == "" {
+				continue
+			}
+			if _, err := regexp.Compile(cond.Regex); IsError(err) {
+				return fmt.Errorf("Rule %q has an invalid regex: %s", rule.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
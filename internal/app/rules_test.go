@@ -0,0 +1,151 @@
+package app
+
+import "testing"
+
+func TestCompareValues(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "5", b: "10", want: -1},
+		{a: "10", b: "5", want: 1},
+		{a: "10", b: "10.0", want: 0},
+		{a: "-2.5", b: "-3", want: 1},
+		{a: "1e3", b: "999", want: 1},
+		// compared as strings when either value is not a number
+		{a: "10", b: "5a", want: -1},
+		{a: "abc", b: "abd", want: -1},
+		{a: "2021-06-01", b: "2021-05-31", want: 1},
+		{a: "2021-06-01T10:00:00+02:00", b: "2021-06-01T10:00:00+02:00", want: 0},
+		{a: "", b: "0", want: -1},
+	}
+
+	for _, tt := range tests {
+		if got := compareValues(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareValues(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestGetFileMetaField(t *testing.T) {
+	file := FileMeta{
+		CameraModel: "Canon EOS R5",
+		Size:        2048,
+		IsRaw:       true,
+		Duration:    12.5,
+		GPS:         GPSData{Position: GPSCoord{Latitude: 41.3874, Longitude: 2.1686}, Timezone: "Europe/Madrid"},
+		Exif: ExifData{
+			Data:     ExifToolData{CreatorTool: "Adobe Lightroom", Model: "EOS R5"},
+			DataDump: RawJsonMap{"LensModel": "RF24-105mm F4 L IS USM", "ISO": float64(400), "Model": "dump"},
+		},
+	}
+
+	tests := []struct {
+		field     string
+		want      string
+		wantFound bool
+	}{
+		{field: "CameraModel", want: "Canon EOS R5", wantFound: true},
+		{field: "Size", want: "2048", wantFound: true},
+		{field: "IsRaw", want: "true", wantFound: true},
+		{field: "Duration", want: "12.5", wantFound: true},
+		{field: "GPS.Timezone", want: "Europe/Madrid", wantFound: true},
+		{field: "GPS.Position.Latitude", want: "41.3874", wantFound: true},
+		{field: "Category", want: "", wantFound: true},
+		// parsed exiftool data, then any other tag
+		{field: "CreatorTool", want: "Adobe Lightroom", wantFound: true},
+		{field: "Model", want: "EOS R5", wantFound: true},
+		{field: "LensModel", want: "RF24-105mm F4 L IS USM", wantFound: true},
+		{field: "ISO", want: "400.000000", wantFound: true},
+		// missing fields
+		{field: "Unknown", wantFound: false},
+		{field: "GPS.Unknown", wantFound: false},
+		{field: "CameraModel.Name", wantFound: false},
+		{field: "", wantFound: false},
+		// structs, maps and slices have no single value
+		{field: "GPS", wantFound: false},
+		{field: "GPS.Position", wantFound: false},
+		{field: "Aliases", wantFound: false},
+	}
+
+	for _, tt := range tests {
+		got, found := GetFileMetaField(file, tt.field)
+		if found != tt.wantFound || got != tt.want {
+			t.Errorf("GetFileMetaField(%q) = %q, %v, want %q, %v", tt.field, got, found, tt.want, tt.wantFound)
+		}
+	}
+}
+
+func TestMatchRoutingRule(t *testing.T) {
+	equals := func(value string) *string { return &value }
+	params := CmdOptions{Rules: []RoutingRule{
+		{Name: "dji", Match: []RuleCondition{{Field: "CameraModel", Equals: equals("dji fc3170")}}, Destination: "dji"},
+		{Name: "edited", Match: []RuleCondition{{Field: "CreatorTool", Contains: "lightroom"}}, Destination: "edited"},
+		{Name: "canon", Match: []RuleCondition{{Field: "CameraModel", Regex: "^Canon "}}, Destination: "canon"},
+		{Name: "long videos", Match: []RuleCondition{
+			{Field: "MediaType", Equals: equals("video")},
+			{Field: "Duration", Min: "60"},
+		}, Destination: "long"},
+		{Name: "short videos", Match: []RuleCondition{
+			{Field: "MediaType", Equals: equals("video")},
+			{Field: "Duration", Max: "10"},
+		}, Destination: "short"},
+		{Name: "2020", Match: []RuleCondition{
+			{Field: "CreationTime", Min: "2020-01-01", Max: "2020-12-31T23:59:59Z"},
+		}, Destination: "2020"},
+		{Name: "lens", Match: []RuleCondition{{Field: "LensModel", Contains: "RF"}}, Destination: "rf"},
+		{Name: "raw", Match: []RuleCondition{{Field: "IsRaw", Equals: equals("TRUE")}}, Destination: "raw"},
+		{Name: "struct", Match: []RuleCondition{{Field: "GPS", Equals: equals("")}}, Destination: "gps"},
+		{Name: "iso", Match: []RuleCondition{{Field: "ISO", Min: "1600"}}, Destination: "iso"},
+		{Name: "empty category", Match: []RuleCondition{{Field: "Category", Equals: equals("")}}, Destination: "other"},
+	}}
+
+	tests := []struct {
+		name string
+		file FileMeta
+		want string
+	}{
+		{name: "equals ignoring case", file: FileMeta{CameraModel: "DJI FC3170", Category: "x"}, want: "dji"},
+		{name: "first matching rule wins", file: FileMeta{
+			CameraModel: "DJI FC3170",
+			Exif:        ExifData{Data: ExifToolData{CreatorTool: "Lightroom"}},
+		}, want: "dji"},
+		{name: "contains ignoring case", file: FileMeta{
+			Category: "edited",
+			Exif:     ExifData{Data: ExifToolData{CreatorTool: "Adobe Lightroom Classic"}},
+		}, want: "edited"},
+		{name: "regex", file: FileMeta{CameraModel: "Canon EOS R5", Category: "x"}, want: "canon"},
+		{name: "regex is case sensitive", file: FileMeta{CameraModel: "canon eos r5", Category: "x"}, want: ""},
+		{name: "all conditions", file: FileMeta{MediaType: "video", Duration: 120, Category: "x"}, want: "long"},
+		{name: "min compared as numbers", file: FileMeta{MediaType: "video", Duration: 9.5, Category: "x"},
+			want: "short"},
+		{name: "some conditions", file: FileMeta{MediaType: "video", Duration: 30, Category: "x"}, want: ""},
+		{name: "dates compared as strings", file: FileMeta{CreationTime: "2020-06-15T12:00:00Z", Category: "x"},
+			want: "2020"},
+		{name: "date out of range", file: FileMeta{CreationTime: "2021-01-01T00:00:00Z", Category: "x"}, want: ""},
+		{name: "exiftool tag", file: FileMeta{
+			Category: "x",
+			Exif:     ExifData{DataDump: RawJsonMap{"LensModel": "RF50mm F1.8 STM"}},
+		}, want: "rf"},
+		{name: "missing tag", file: FileMeta{Category: "x", Exif: ExifData{DataDump: RawJsonMap{}}}, want: ""},
+		{name: "empty value", file: FileMeta{}, want: "other"},
+		{name: "bool field", file: FileMeta{IsRaw: true, Category: "x"}, want: "raw"},
+		{name: "struct field", file: FileMeta{GPS: GPSData{Timezone: "UTC"}, Category: "x"}, want: ""},
+		{name: "numeric tag", file: FileMeta{Category: "x", Exif: ExifData{DataDump: RawJsonMap{"ISO": float64(3200)}}},
+			want: "iso"},
+		{name: "numeric tag below min", file: FileMeta{Category: "x",
+			Exif: ExifData{DataDump: RawJsonMap{"ISO": float64(400)}}}, want: ""},
+		// not a number, so it's compared as a string with the bound
+		{name: "text tag with min", file: FileMeta{Category: "x",
+			Exif: ExifData{DataDump: RawJsonMap{"ISO": "Auto"}}}, want: "iso"},
+		{name: "text tag below min", file: FileMeta{Category: "x",
+			Exif: ExifData{DataDump: RawJsonMap{"ISO": "100 (auto)"}}}, want: ""},
+	}
+
+	for _, tt := range tests {
+		rule, matched := matchRoutingRule(params, tt.file)
+		if matched != (tt.want != "") || rule.Destination != tt.want {
+			t.Errorf("matchRoutingRule() with %s = %q, %v, want %q", tt.name, rule.Destination, matched, tt.want)
+		}
+	}
+}
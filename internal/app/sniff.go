@@ -0,0 +1,138 @@
+package app
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+)
+
+const sniffLen = 512
+
+// ftypBrands maps the major brand of ISO base media files (MP4, MOV, HEIC, CR3, etc.) to their file extension.
+var ftypBrands = map[string]string{
+	"heic": ".heic", "heix": ".heic", "hevc": ".heic", "heim": ".heic", "heis": ".heic",
+	"mif1": ".heif", "msf1": ".heif", "avif": ".avif",
+	"crx ": ".cr3",
+	"qt  ": ".mov",
+	"3gp4": ".3gp", "3gp5": ".3gp", "3gp6": ".3gp", "3g2a": ".3gp",
+	"m4v ": ".m4v", "M4V ": ".m4v",
+	"M4A ": ".m4a", "M4B ": ".m4a", "M4P ": ".m4a", "F4A ": ".m4a",
+}
+
+// SniffFileType detects the real type of a file by its magic bytes, returning its media type and extension.
+// Audio files return an empty media type, and unknown files return empty values.
+func SniffFileType(path string) (string, string) {
+	f, err := os.Open(path)
+	if IsError(err) {
+		return "", ""
+	}
+	defer f.Close()
+
+	header := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, header)
+	if IsError(err) && n == 0 {
+		return "", ""
+	}
+
+	return sniffHeader(header[:n])
+}
+
+func sniffHeader(h []byte) (string, string) {
+	hasPrefix := func(offset int, magic string) bool {
+		return len(h) >= offset+len(magic) && string(h[offset:offset+len(magic)]) == magic
+	}
+
+	switch {
+	case hasPrefix(0, "\xFF\xD8\xFF"):
+		return MediaTypeImage, ".jpg"
+	case hasPrefix(0, "\x89PNG\r\n\x1A\n"):
+		return MediaTypeImage, ".png"
+	case hasPrefix(0, "GIF87a"), hasPrefix(0, "GIF89a"):
+		return MediaTypeImage, ".gif"
+	case hasPrefix(0, "RIFF") && hasPrefix(8, "WEBP"):
+		return MediaTypeImage, ".webp"
+	case hasPrefix(0, "RIFF") && hasPrefix(8, "AVI "):
+		return MediaTypeVideo, ".avi"
+	case hasPrefix(0, "RIFF") && hasPrefix(8, "WAVE"):
+		return "", ".wav"
+	case hasPrefix(0, "ID3"), hasPrefix(0, "\xFF\xFB"), hasPrefix(0, "\xFF\xF3"):
+		return "", ".mp3"
+	case hasPrefix(0, "fLaC"):
+		return "", ".flac"
+	case hasPrefix(0, "BM") && hasPrefix(6, "\x00\x00\x00\x00"):
+		return MediaTypeImage, ".bmp"
+	case hasPrefix(0, "FUJIFILMCCD-RAW"):
+		return MediaTypeImage, ".raf"
+	case hasPrefix(0, "IIRO"), hasPrefix(0, "IIRS"), hasPrefix(0, "MMOR"):
+		return MediaTypeImage, ".orf"
+	case hasPrefix(0, "IIU\x00"):
+		return MediaTypeImage, ".rw2"
+	case hasPrefix(0, "II*\x00") && hasPrefix(8, "CR"):
+		return MediaTypeImage, ".cr2"
+	case hasPrefix(0, "II*\x00"), hasPrefix(0, "MM\x00*"):
+		// NEF, ARW, DNG and most other RAW formats are TIFF-based too, so exiftool has the final word
+		return MediaTypeImage, ".tif"
+	case hasPrefix(0, "8BPS"):
+		return MediaTypeImage, ".psd"
+	case hasPrefix(4, "ftyp"):
+		brand := ""
+		if len(h) >= 12 {
+			brand = string(h[8:12])
+		}
+		if ext, ok := ftypBrands[brand]; ok {
+			return getMediaType(ext), ext
+		}
+		return MediaTypeVideo, ".mp4"
+	case hasPrefix(0, "\x1A\x45\xDF\xA3"):
+		if bytes.Contains(h, []byte("webm")) {
+			return MediaTypeVideo, ".webm"
+		}
+		return MediaTypeVideo, ".mkv"
+	case hasPrefix(0, "FLV\x01"):
+		return MediaTypeVideo, ".flv"
+	case hasPrefix(0, "\x00\x00\x01\xBA"), hasPrefix(0, "\x00\x00\x01\xB3"):
+		return MediaTypeVideo, ".mpg"
+	case hasPrefix(0, "\x30\x26\xB2\x75\x8E\x66\xCF\x11"):
+		return MediaTypeVideo, ".wmv"
+	case hasPrefix(0, "OggS") && bytes.Contains(h, []byte("theora")):
+		return MediaTypeVideo, ".ogv"
+	case hasPrefix(0, "OggS"):
+		return "", ".ogg"
+	case hasPrefix(0, "G") && len(h) > 188 && h[188] == 'G':
+		return MediaTypeVideo, ".ts"
+	case bytes.HasPrefix(bytes.TrimSpace(h), []byte("<svg")), bytes.HasPrefix(h, []byte("<?xml")) && bytes.Contains(h, []byte("<svg")):
+		return MediaTypeImage, ".svg"
+	}
+
+	return "", ""
+}
+
+// detectFileType resolves the real media type, MIME type and extension of a file. The MIMEType and
+// FileTypeExtension reported by exiftool take precedence over the magic bytes, since they are more accurate
+// (e.g. to tell apart audio-only MP4 files).
+func detectFileType(file FileMeta) (string, string, string) {
+	mediaType, ext := SniffFileType(file.Source.Path)
+	mimeType := file.Exif.Data.MIMEType
+
+	if file.Exif.Data.FileTypeExtension != "" {
+		ext = "." + strings.ToLower(file.Exif.Data.FileTypeExtension)
+		mediaType = getMediaType(ext)
+	}
+
+	switch strings.SplitN(mimeType, "/", 2)[0] {
+	case "image":
+		mediaType = MediaTypeImage
+	case "video":
+		mediaType = MediaTypeVideo
+	case "audio":
+		mediaType = ""
+	}
+
+	if ext == "" {
+		// unknown content, so trust the file extension
+		return file.MediaType, mimeType, file.Source.Extension
+	}
+
+	return mediaType, mimeType, ext
+}
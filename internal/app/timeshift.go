@@ -0,0 +1,207 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TimeShift corrects the clock of a camera model by adding Shift (e.g. "-1h", "+2h30m") to the creation time
+// of its files, optionally only for the ones taken between the From and To dates (inclusive, YYYY-MM-DD,
+// compared against the uncorrected camera time).
+type TimeShift struct {
+	Camera string `yaml:"camera"`
+	From   string `yaml:"from,omitempty"`
+	To     string `yaml:"to,omitempty"`
+	Shift  string `yaml:"shift"`
+}
+
+var regexTimeShift = regexp.MustCompile(`^(.+?)(?:@([0-9-]*)\.\.([0-9-]*))?=([+-]?[0-9hms.]+)$`)
+
+// ParseTimeShift parses a time shift like "Canon EOS R6=-1h" or "Canon EOS R6@2023-05-01..2023-05-20=+30m".
+func ParseTimeShift(value string) (TimeShift, error) {
+	matches := regexTimeShift.FindStringSubmatch(strings.TrimSpace(value))
+	if matches == nil {
+		return TimeShift{}, fmt.Errorf("Invalid time shift %q, the expected format is camera[@from..to]=shift.", value)
+	}
+
+	shift := TimeShift{Camera: strings.TrimSpace(matches[1]), From: matches[2], To: matches[3], Shift: matches[4]}
+
+	return shift, validateTimeShifts([]TimeShift{shift})
+}
+
+// applyTimeShift adds the shift of the first time shift rule matching the camera and date of the file.
+// It returns the corrected date and the applied shift, if any.
+func applyTimeShift(params CmdOptions, cameraModel string, date time.Time) (time.Time, string) {
+	for _, shift := range params.TimeShifts {
+		if cameraModel == "" || !strings.EqualFold(shift.Camera, cameraModel) {
+			continue
+		}
+
+		if shift.From != "" {
+			from, err := time.ParseInLocation(FilterDateFormat, shift.From, date.Location())
+			if IsError(err) || date.Before(from) {
+				continue
+			}
+		}
+
+		if shift.To != "" {
+			to, err := time.ParseInLocation(FilterDateFormat, shift.To, date.Location())
+			if IsError(err) || !date.Before(to.AddDate(0, 0, 1)) {
+				continue
+			}
+		}
+
+		duration, err := time.ParseDuration(strings.TrimPrefix(shift.Shift, "+"))
+		if IsError(err) {
+			continue
+		}
+
+		return date.Add(duration), shift.Shift
+	}
+
+	return date, ""
+}
+
+func validateTimeShifts(shifts []TimeShift) error {
+	for _, shift := range shifts {
+		if shift.Camera == "" {
+			return errors.New("Time shifts must have a camera model.")
+		}
+		if _, err := time.ParseDuration(strings.TrimPrefix(shift.Shift, "+")); IsError(err) {
+			return fmt.Errorf("Invalid shift for camera %q: %s", shift.Camera, err)
+		}
+		for _, date := range []string{shift.From, shift.To} {
+			if _, err := time.Parse(FilterDateFormat, date); date != "" && IsError(err) {
+				return fmt.Errorf("Invalid date %q for camera %q, the expected format is YYYY-MM-DD.", date, shift.Camera)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ClockSkewEstimate is the estimated difference between the clock of a camera and a reference camera.
+type ClockSkewEstimate struct {
+	Camera    string
+	Reference string
+	Pairs     int           // number of photos of both cameras taken at the same place and around the same time
+	Skew      time.Duration // how much the camera clock is ahead of the reference one (negative if behind)
+}
+
+// TimeShift returns the time shift rule that corrects the estimated skew.
+func (e ClockSkewEstimate) TimeShift() string {
+	shift := -e.Skew.Round(time.Second)
+	if shift >= 0 {
+		return fmt.Sprintf("%s=+%s", e.Camera, shift)
+	}
+
+	return fmt.Sprintf("%s=%s", e.Camera, shift)
+}
+
+type skewSample struct {
+	Time   time.Time
+	Coords GPSCoord
+}
+
+// readSkewSample reads the creation time and GPS position of a file taken with any of the cameras, if it's
+// geotagged, and returns which camera took it. Only the metadata needed for that is read, and the file is not hashed.
+func readSkewSample(ctx context.Context, params CmdOptions, path string, info os.FileInfo, cameras ...string) (string, skewSample, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	file := FileMeta{
+		Source:           FilePathInfo{Path: path, Dirname: filepath.Dir(path), Extension: ext},
+		MediaType:        getMediaType(ext),
+		ModificationTime: info.ModTime().Format(DateFormat),
+	}
+
+	file.Exif = parseMetadata(ctx, params, file)
+	file.CameraModel = parseExifCameraName(file.Exif.Data)
+
+	cameraIndex := slices.IndexFunc(cameras, func(camera string) bool { return strings.EqualFold(camera, file.CameraModel) })
+	if cameraIndex < 0 {
+		return "", skewSample{}, false
+	}
+
+	file.GPS = GPSDataParse(file.Exif.Data)
+	if !file.GPS.HasPosition {
+		return "", skewSample{}, false
+	}
+
+	t, _, _ := resolveFileDate(params, &file)
+
+	return cameras[cameraIndex], skewSample{Time: t, Coords: file.GPS.Position}, true
+}
+
+// EstimateClockSkew compares the geotagged files of two cameras in the source directory. For every file of the
+// camera, it takes the closest in time of the reference camera files taken within maxDistance meters and
+// maxGap, and the median of those time differences is the estimated skew.
+func EstimateClockSkew(ctx context.Context, params CmdOptions, camera string, reference string, maxDistance float64, maxGap time.Duration) (ClockSkewEstimate, error) {
+	estimate := ClockSkewEstimate{Camera: camera, Reference: reference}
+	samples := map[string][]skewSample{}
+
+	// the current shifts would bias the estimation, and so would the positions interpolated from the track logs
+	// with the skewed clock
+	params.TimeShifts = nil
+	params.GPXFiles, params.GPXTrack = nil, nil
+
+	_, err := walkDir(ctx, params, func(stats *CmdFileStats, path string, info os.FileInfo, err error) error {
+		if IsError(err) {
+			return err
+		}
+
+		if cameraModel, sample, ok := readSkewSample(ctx, params, path, info, camera, reference); ok {
+			samples[cameraModel] = append(samples[cameraModel], sample)
+		}
+
+		return ctx.Err()
+	})
+	if IsError(err) {
+		return estimate, err
+	}
+
+	var diffs []time.Duration
+	for _, sample := range samples[camera] {
+		closest := time.Duration(math.MaxInt64)
+		for _, refSample := range samples[reference] {
+			if GPSDistance(sample.Coords, refSample.Coords) > maxDistance {
+				continue
+			}
+			diff := sample.Time.Sub(refSample.Time)
+			if absDuration(diff) <= maxGap && absDuration(diff) < absDuration(closest) {
+				closest = diff
+			}
+		}
+		if closest != time.Duration(math.MaxInt64) {
+			diffs = append(diffs, closest)
+		}
+	}
+
+	if len(diffs) == 0 {
+		return estimate, fmt.Errorf("No geotagged files of %q and %q were taken at the same place and time.", camera, reference)
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i] < diffs[j] })
+	estimate.Pairs = len(diffs)
+	estimate.Skew = diffs[len(diffs)/2]
+	if len(diffs)%2 == 0 {
+		estimate.Skew = (diffs[len(diffs)/2-1] + diffs[len(diffs)/2]) / 2
+	}
+
+	return estimate, nil
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+
+	return d
+}
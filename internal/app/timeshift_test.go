@@ -0,0 +1,108 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeShift(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    TimeShift
+		wantErr bool
+	}{
+		{value: "", wantErr: true},
+		{value: "garbage", wantErr: true},
+		{value: "=-1h", wantErr: true},
+		{value: "Canon EOS R6=", wantErr: true},
+		{value: "Canon EOS R6=1 hour", wantErr: true},
+		{value: "Canon EOS R6=1x", wantErr: true},
+		{value: "Canon EOS R6@2023-05..2023-05-20=-1h", wantErr: true},
+		{value: "Canon EOS R6@2023-05-01..2023-13-20=-1h", wantErr: true},
+		{value: "Canon EOS R6=-1h", want: TimeShift{Camera: "Canon EOS R6", Shift: "-1h"}},
+		{value: " Canon EOS R6 =+1h30m ", want: TimeShift{Camera: "Canon EOS R6", Shift: "+1h30m"}},
+		{value: "iPhone 12=90s", want: TimeShift{Camera: "iPhone 12", Shift: "90s"}},
+		{
+			value: "Canon EOS R6@2023-05-01..2023-05-20=-1h",
+			want:  TimeShift{Camera: "Canon EOS R6", From: "2023-05-01", To: "2023-05-20", Shift: "-1h"},
+		},
+		{
+			value: "Canon EOS R6@2023-05-01..=+30m",
+			want:  TimeShift{Camera: "Canon EOS R6", From: "2023-05-01", Shift: "+30m"},
+		},
+		{
+			value: "Canon EOS R6@..2023-05-20=+30m",
+			want:  TimeShift{Camera: "Canon EOS R6", To: "2023-05-20", Shift: "+30m"},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTimeShift(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTimeShift(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseTimeShift(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestApplyTimeShift(t *testing.T) {
+	params := CmdOptions{TimeShifts: []TimeShift{
+		{Camera: "Canon EOS R6", From: "2023-05-01", To: "2023-05-20", Shift: "-1h"},
+		{Camera: "Canon EOS R6", Shift: "+30m"},
+		{Camera: "iPhone 12", Shift: "90s"},
+	}}
+	date := func(month time.Month, day int, hour int) time.Time {
+		return time.Date(2023, month, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name      string
+		camera    string
+		date      time.Time
+		want      time.Time
+		wantShift string
+	}{
+		{name: "no camera", date: date(5, 10, 12), want: date(5, 10, 12)},
+		{name: "other camera", camera: "Nikon Z6", date: date(5, 10, 12), want: date(5, 10, 12)},
+		{name: "inside the range", camera: "Canon EOS R6", date: date(5, 10, 12), want: date(5, 10, 11), wantShift: "-1h"},
+		{name: "first day", camera: "Canon EOS R6", date: date(5, 1, 0), want: date(4, 30, 23), wantShift: "-1h"},
+		{name: "last day", camera: "Canon EOS R6", date: date(5, 20, 23), want: date(5, 20, 22), wantShift: "-1h"},
+		{
+			name: "after the range", camera: "Canon EOS R6", date: date(5, 21, 0),
+			want: date(5, 21, 0).Add(30 * time.Minute), wantShift: "+30m",
+		},
+		{name: "case insensitive", camera: "canon eos r6", date: date(5, 10, 12), want: date(5, 10, 11), wantShift: "-1h"},
+		{name: "no sign", camera: "iPhone 12", date: date(5, 10, 12), want: date(5, 10, 12).Add(90 * time.Second), wantShift: "90s"},
+	}
+
+	for _, tt := range tests {
+		got, shift := applyTimeShift(params, tt.camera, tt.date)
+		if !got.Equal(tt.want) || shift != tt.wantShift {
+			t.Errorf("%s: applyTimeShift() = %v, %q, want %v, %q", tt.name, got, shift, tt.want, tt.wantShift)
+		}
+	}
+}
+
+func TestClockSkewEstimateTimeShift(t *testing.T) {
+	tests := []struct {
+		skew time.Duration
+		want string
+	}{
+		{skew: time.Hour, want: "Canon EOS R6=-1h0m0s"},
+		{skew: -90*time.Minute - 400*time.Millisecond, want: "Canon EOS R6=+1h30m0s"},
+		{skew: 0, want: "Canon EOS R6=+0s"},
+	}
+
+	for _, tt := range tests {
+		estimate := ClockSkewEstimate{Camera: "Canon EOS R6", Skew: tt.skew}
+		if got := estimate.TimeShift(); got != tt.want {
+			t.Errorf("TimeShift() with skew %s = %q, want %q", tt.skew, got, tt.want)
+		}
+		if _, err := ParseTimeShift(estimate.TimeShift()); err != nil {
+			t.Errorf("ParseTimeShift(%q) error = %v", estimate.TimeShift(), err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	TimezoneSourceGPS     = "gps"
+	TimezoneSourceOffset  = "offset"
+	TimezoneSourceCamera  = "camera"
+	TimezoneSourceTrip    = "trip"
+	TimezoneSourceDefault = "default"
+)
+
+var regexTimezoneOffset = regexp.MustCompile(`^([+-])([0-9]{2}):?([0-9]{2})$`)
+
+// Trip maps a date range to the timezone the files were taken in, for files without GPS or offset info.
+// The From and To dates are inclusive, in YYYY-MM-DD or RFC3339 format.
+type Trip struct {
+	Name     string `yaml:"name"`
+	From     string `yaml:"from"`
+	To       string `yaml:"to"`
+	Timezone string `yaml:"timezone"`
+}
+
+// LoadTimezone loads a timezone by its IANA name (e.g. "Europe/Berlin") or by its UTC offset (e.g. "+09:00").
+func LoadTimezone(timezone string) (*time.Location, error) {
+	if matches := regexTimezoneOffset.FindStringSubmatch(strings.TrimSpace(timezone)); matches != nil {
+		hours, _ := strconv.Atoi(matches[2])
+		minutes, _ := strconv.Atoi(matches[3])
+		offset := hours*3600 + minutes*60
+		if matches[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(matches[1]+matches[2]+":"+matches[3], offset), nil
+	}
+
+	return time.LoadLocation(timezone)
+}
+
+// resolveTimezone finds the timezone of a file from, in order of preference: its GPS position, the EXIF
+// OffsetTime* tags, the timezone configured for its camera, the trip its creation date falls into,
+// and the default timezone. The creation date can be zero when it's not resolved yet.
+// It returns the timezone and its source.
+func resolveTimezone(params CmdOptions, data FileMeta, creationDate time.Time) (string, string) {
+	if data.GPS.Timezone != "" && data.GPS.TimezoneSource == TimezoneSourceGPS {
+		return data.GPS.Timezone, TimezoneSourceGPS
+	}
+
+	for _, offset := range []string{data.Exif.Data.OffsetTimeOriginal, data.Exif.Data.OffsetTimeDigitized, data.Exif.Data.OffsetTime} {
+		if regexTimezoneOffset.MatchString(offset) {
+			return offset, TimezoneSourceOffset
+		}
+	}
+
+	for camera, timezone := range params.CameraTimezones {
+		if data.CameraModel != "" && strings.EqualFold(camera, data.CameraModel) {
+			return timezone, TimezoneSourceCamera
+		}
+	}
+
+	if !creationDate.IsZero() {
+		for _, trip := range params.Trips {
+			if trip.contains(creationDate) {
+				return trip.Timezone, TimezoneSourceTrip + ":" + trip.Name
+			}
+		}
+	}
+
+	return params.Timezone, TimezoneSourceDefault
+}
+
+func (trip Trip) contains(date time.Time) bool {
+	from, err := trip.parseDate(trip.From, false)
+	if IsError(err) {
+		return false
+	}
+
+	to, err := trip.parseDate(trip.To, true)
+	if IsError(err) {
+		return false
+	}
+
+	return !date.Before(from) && date.Before(to)
+}
+
+// parseDate parses a trip date in its own timezone. Dates without time are expanded to the end of the day
+// when they are the end of the range, so it's inclusive.
+func (trip Trip) parseDate(value string, isEnd bool) (time.Time, error) {
+	loc, err := LoadTimezone(trip.Timezone)
+	if IsError(err) {
+		return time.Time{}, err
+	}
+
+	if t, err := time.Parse(DateFormat, value); !IsError(err) {
+		if isEnd {
+			t = t.Add(time.Second)
+		}
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation(FilterDateFormat, value, loc)
+	if !IsError(err) && isEnd {
+		t = t.AddDate(0, 0, 1)
+	}
+
+	return t, err
+}
+
+// LoadTripsFile reads a YAML file with a list of trips.
+func LoadTripsFile(path string) ([]Trip, error) {
+	data, err := ioutil.ReadFile(path)
+	if IsError(err) {
+		return nil, err
+	}
+
+	var trips []Trip
+	if err = yaml.Unmarshal(data, &trips); IsError(err) {
+		return nil, fmt.Errorf("Cannot parse trips file %s: %s", path, err)
+	}
+
+	return trips, nil
+}
+
+func validateTimezones(params CmdOptions) error {
+	if _, err := LoadTimezone(params.Timezone); IsError(err) {
+		return fmt.Errorf("Invalid timezone: %s", err)
+	}
+
+	for camera, timezone := range params.CameraTimezones {
+		if _, err := LoadTimezone(timezone); IsError(err) {
+			return fmt.Errorf("Invalid timezone for camera %q: %s", camera, err)
+		}
+	}
+
+	for _, trip := range params.Trips {
+		if _, err := trip.parseDate(trip.From, false); IsError(err) {
+			return fmt.Errorf("Invalid trip %q: %s", trip.Name, err)
+		}
+		if _, err := trip.parseDate(trip.To, true); IsError(err) {
+			return fmt.Errorf("Invalid trip %q: %s", trip.Name, err)
+		}
+	}
+
+	return nil
+}
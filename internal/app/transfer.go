@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+const (
+	ModeCopy     = "copy"
+	ModeMove     = "move"
+	ModeHardlink = "hardlink"
+	ModeSymlink  = "symlink"
+	ModeReflink  = "reflink" // copy-on-write clone, on filesystems like Btrfs or XFS
+)
+
+var Modes = []string{ModeCopy, ModeMove, ModeHardlink, ModeSymlink, ModeReflink}
+
+// TransferFile puts the source file in the destination with the given mode. When links or clones are not
+// supported, e.g. across filesystems, it falls back to a copy. It returns the mode that was actually used.
+func TransferFile(ctx context.Context, src string, dest string, mode string) (string, error) {
+	var err error
+
+	switch mode {
+	case ModeCopy:
+		return ModeCopy, FileCopy(ctx, src, dest, true)
+	case ModeMove:
+		return ModeMove, FileMove(ctx, src, dest)
+	case ModeHardlink:
+		err = os.Link(src, dest)
+	case ModeSymlink:
+		var target string
+		if target, err = filepath.Abs(src); !IsError(err) {
+			err = os.Symlink(target, dest)
+		}
+	case ModeReflink:
+		err = FileReflink(src, dest)
+	default:
+		return mode, fmt.Errorf("Unknown mode %q, the supported ones are: %v.", mode, Modes)
+	}
+
+	if isLinkUnsupported(err) {
+		Logger.Warn("link not supported, copying the file", "path", src, "mode", mode, "error", err)
+		os.Remove(dest)
+		return ModeCopy, FileCopy(ctx, src, dest, true)
+	}
+
+	return mode, err
+}
+
+// isLinkUnsupported tells if the link or clone failed because the filesystems don't support it. Other errors, like
+// missing permissions, are returned instead of hidden behind a copy.
+func isLinkUnsupported(err error) bool {
+	for _, unsupported := range []error{
+		syscall.EXDEV,
+		syscall.ENOTSUP,
+		syscall.EOPNOTSUPP,
+		errors.ErrUnsupported,
+	} {
+		if errors.Is(err, unsupported) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsLinkMode tells if the destination files of the mode share their content with the source ones,
+// so they cannot be modified without modifying the source files too.
+func IsLinkMode(mode string) bool {
+	return mode == ModeHardlink || mode == ModeSymlink
+}
+
+func validateMode(mode string) error {
+	for _, supported := range Modes {
+		if mode == supported {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Unknown mode %q, the supported ones are: %v.", mode, Modes)
+}
@@ -0,0 +1,30 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestIsLinkUnsupported(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: nil, want: false},
+		{err: &os.LinkError{Op: "link", Old: "a", New: "b", Err: syscall.EXDEV}, want: true},
+		{err: &os.PathError{Op: "reflink", Path: "b", Err: syscall.EOPNOTSUPP}, want: true},
+		{err: &os.LinkError{Op: "reflink", Old: "a", New: "b", Err: errors.ErrUnsupported}, want: true},
+		{err: &os.LinkError{Op: "link", Old: "a", New: "b", Err: syscall.EPERM}, want: false},
+		{err: &os.LinkError{Op: "symlink", Old: "a", New: "b", Err: syscall.EACCES}, want: false},
+		{err: &os.PathError{Op: "reflink", Path: "b", Err: syscall.EINVAL}, want: false},
+		{err: &os.LinkError{Op: "link", Old: "a", New: "b", Err: os.ErrExist}, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isLinkUnsupported(tt.err); got != tt.want {
+			t.Errorf("isLinkUnsupported(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
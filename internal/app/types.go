@@ -7,23 +7,93 @@ import (
 type RawJsonMap map[string]interface{}
 
 type CmdOptions struct {
-	CurrentTime   time.Time // TODO: calculate elapsed time
-	SrcDir        string
-	DestDir       string
-	DryRun        bool
-	Limit         uint
-	Extensions    string
-	ConvertVideos bool
-	FixDates      bool
-	Move          bool
-	Quiet         bool
+	CurrentTime        time.Time             `yaml:"-"` // start time of the run, to calculate the elapsed time
+	SrcDir             string                `yaml:"-"`
+	DestDir            string                `yaml:"-"`
+	DryRun             bool                  `yaml:"dry_run"`
+	Limit              uint                  `yaml:"limit"`
+	Extensions         string                `yaml:"extensions"`
+	ConvertVideos      bool                  `yaml:"convert_videos"`
+	FixDates           bool                  `yaml:"fix_dates"`
+	Move               bool                  `yaml:"move"` // same as the move mode
+	Mode               string                `yaml:"mode"` // copy, move, hardlink, symlink or reflink
+	Quiet              bool                  `yaml:"quiet"`
+	SeparateRaw        bool                  `yaml:"separate_raw"`
+	Timezone           string                `yaml:"timezone"`
+	MinFileSize        int64                 `yaml:"min_file_size"`
+	ExcludeDirs        string                `yaml:"exclude_dirs"`
+	ScreenShots        string                `yaml:"screenshots"`
+	DirMetadata        string                `yaml:"metadata_dir"`
+	DirImages          string                `yaml:"images_dir"`
+	DirImagesRaw       string                `yaml:"raw_dir"`
+	DirVideos          string                `yaml:"videos_dir"`
+	Include            []string              `yaml:"include"`
+	Exclude            []string              `yaml:"exclude"`
+	MaxFileSize        int64                 `yaml:"max_file_size"`
+	Since              string                `yaml:"since"`
+	Until              string                `yaml:"until"`
+	Verbosity          int                   `yaml:"verbosity"` // 1 logs the decision taken for every file, 2 also the scanned ones
+	LogFile            string                `yaml:"log_file"`
+	LogFormat          string                `yaml:"log_format"` // text or json
+	ReportFile         string                `yaml:"report_file"`
+	ReportFormat       string                `yaml:"report_format"` // text, json, markdown or html
+	DuplicatesFile     string                `yaml:"duplicates_file"`
+	DuplicatesFormat   string                `yaml:"duplicates_format"` // csv or json
+	DuplicatesAction   string                `yaml:"duplicates_action"` // quarantine or delete the source duplicates
+	QuarantineDir      string                `yaml:"quarantine_dir"`
+	FixExtensions      bool                  `yaml:"fix_extensions"`
+	Messaging          string                `yaml:"messaging"`
+	Edited             string                `yaml:"edited"`
+	Routes             map[string]string     `yaml:"routes"` // destination directory by file category, e.g. "screenshot"
+	Rules              []RoutingRule         `yaml:"rules"`
+	FilenameDates      []FilenameDatePattern `yaml:"filename_dates"`
+	DateSources        []string              `yaml:"date_sources"`     // creation date sources, from the most to the least reliable
+	CameraTimezones    map[string]string     `yaml:"camera_timezones"` // timezone or UTC offset by camera model
+	Trips              []Trip                `yaml:"trips"`
+	TimeShifts         []TimeShift           `yaml:"time_shifts"`
+	DestPattern        string                `yaml:"dest_pattern"`         // destination directory pattern, e.g. "{media}/{year}/{month}"
+	GeocodeMaxDistance float64               `yaml:"geocode_max_distance"` // in km
+	GPXFiles           []string              `yaml:"gpx_files"`
+	GPXMaxGap          string                `yaml:"gpx_max_gap"` // e.g. "30m"
+	GPXWrite           bool                  `yaml:"gpx_write"`   // write the GPS tags of the geotagged files into the destination files
+	GPXTrack           GPXTrack              `yaml:"-"`
+	SourceType         string                `yaml:"source_type"` // dir or dcim
+	OnlyNew            bool                  `yaml:"only_new"`    // skip the files imported from the same card before
+	DCIM               DCIMSource            `yaml:"-"`
+	PairCache          *PairCache            `yaml:"-"` // the RAW+JPEG pair files seen during the run
 }
 
 type CmdFileStats struct {
 	ProcessedFiles  int
 	SkippedFiles    int
 	DuplicatedFiles int
+	FailedFiles     int
 	TotalSize       int64
+	ScannedSize     int64 // size of all the scanned files, including the skipped ones
+	SkipReasons     map[string]int
+	Duplicates      []DuplicateRecord
+	Failures        []FailureRecord
+	ByYear          map[string]StatsGroup // processed files by year of creation
+	ByCamera        map[string]StatsGroup
+	ByMediaType     map[string]StatsGroup
+	Interrupted     bool // the run was stopped before processing all the files
+}
+
+type StatsGroup struct {
+	Files int
+	Size  int64
+}
+
+type DuplicateRecord struct {
+	Source      string
+	DuplicateOf string
+	Match       string // checksum or path
+	Metadata    string // metadata file of the library file, for checksum matches
+}
+
+type FailureRecord struct {
+	Source string
+	Error  string
 }
 
 type FilePathInfo struct {
@@ -41,13 +111,30 @@ type FileMeta struct {
 	Checksum          string
 	CreationTime      string
 	ModificationTime  string
+	DateSource        string // Metadata tag or other source the CreationTime was taken from
+	DateConfidence    string // high, medium or low
+	TimeShift         string // Correction applied to the creation time for the camera clock skew, if any
 	MediaType         string
+	MimeType          string
+	DetectedExtension string // Real extension of the file, detected by its content
 	CameraModel       string
+	Duration          float64 // Duration of videos, in seconds
 	CreationTool      string
 	IsScreenShot      bool
+	Category          string // screenshot, messaging, edited or empty
+	IsRaw             bool
+	PairedWith        string // Source path of the RAW or JPEG/HEIC file shot together with this one
+	PairChecksum      string // Checksum shared by both files of a RAW+JPEG pair in their destination names
 	IsDuplication     bool
+	DuplicateOf       string // Path of the already imported file with the same checksum or destination
+	DuplicateMatch    string // checksum or path
 	IsAlreadyImported bool
 	IsLegacyVideo     bool
+	MatchedRule       string   // Name of the routing rule that decided the destination, if any
+	ImportMode        string   // How the file was put in the destination: copy, move, hardlink, symlink or reflink
+	Aliases           []string // Other paths of the library hardlinked to this file by dedupe, relative to the library
 	Exif              ExifData
 	GPS               GPSData
+	Location          Location
+	Volume            VolumeInfo // Memory card the file was imported from, with the dcim source type
 }
@@ -4,11 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"log"
+	"math"
+	"os"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
-
-	tm "github.com/buger/goterm"
 )
 
 func IsError(e error) bool {
@@ -17,7 +18,8 @@ func IsError(e error) bool {
 
 func HandleError(e error) {
 	if IsError(e) {
-		log.Fatal(fmt.Sprintf("[%s] ERROR: %s\n", AppName, e))
+		Logger.Error(e.Error())
+		os.Exit(1)
 	}
 }
 
@@ -25,13 +27,6 @@ func PrintLn(template string, args ...interface{}) {
 	fmt.Printf("["+AppName+"] "+template+"\n", args...)
 }
 
-func PrintReplaceLn(template string, args ...interface{}) {
-	tm.Clear()
-	tm.MoveCursor(1, 1)
-	tm.Printf(template, args...)
-	tm.Flush()
-}
-
 func TotalBytesToString(b int64, useDecimalSystem bool) string {
 	unit := int64(1024)
 	format := "%.1f %ciB"
@@ -54,6 +49,32 @@ func TotalBytesToString(b int64, useDecimalSystem bool) string {
 	return fmt.Sprintf(format, float64(b)/float64(div), "kMGTPE"[exp])
 }
 
+// ParseBytes parses a size like "500", "50KB", "1.5 MB" or "2GiB" into bytes.
+// KB, MB, GB and TB are decimal units, while KiB, MiB, GiB and TiB are binary ones.
+func ParseBytes(size string) (int64, error) {
+	matches := regexp.MustCompile(`(?i)^\s*([0-9]+(?:\.[0-9]+)?)\s*([kmgt]?)(i?)b?\s*$`).FindStringSubmatch(size)
+	if matches == nil {
+		return 0, fmt.Errorf("Invalid size %q", size)
+	}
+
+	value, err := strconv.ParseFloat(matches[1], 64)
+	if IsError(err) {
+		return 0, err
+	}
+
+	unit := 1000.0
+	if matches[3] != "" {
+		unit = 1024
+	}
+
+	exp := strings.Index("kmgt", strings.ToLower(matches[2])) + 1
+	if matches[2] == "" {
+		exp = 0
+	}
+
+	return int64(value * math.Pow(unit, float64(exp))), nil
+}
+
 func ToString(val interface{}) string {
 	switch val.(type) {
 	case int:
@@ -67,7 +88,7 @@ func ToString(val interface{}) string {
 
 func FormatDateWithTimezone(date time.Time, timezone string) string {
 	if timezone != "" {
-		loc, err := time.LoadLocation(timezone)
+		loc, err := LoadTimezone(timezone)
 		if !IsError(err) {
 			date = date.In(loc)
 		}
@@ -80,7 +101,7 @@ func ParseDateWithTimezone(layout string, value string, timezone string) (time.T
 	t, err := time.Parse(layout, value)
 
 	if !IsError(err) && (timezone != "") {
-		loc, err := time.LoadLocation(timezone)
+		loc, err := LoadTimezone(timezone)
 		if !IsError(err) {
 			t = t.In(loc)
 		}
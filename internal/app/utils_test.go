@@ -0,0 +1,40 @@
+package app
+
+import "testing"
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		size    string
+		want    int64
+		wantErr bool
+	}{
+		{size: "", wantErr: true},
+		{size: "garbage", wantErr: true},
+		{size: "-1KB", wantErr: true},
+		{size: "1PB", wantErr: true},
+		{size: "1 K B", wantErr: true},
+		{size: "0", want: 0},
+		{size: "512", want: 512},
+		{size: "512B", want: 512},
+		{size: "50KB", want: 50000},
+		{size: "50kb", want: 50000},
+		{size: "50K", want: 50000},
+		{size: "1.5MB", want: 1500000},
+		{size: " 2 GB ", want: 2000000000},
+		{size: "1TB", want: 1000000000000},
+		{size: "1KiB", want: 1024},
+		{size: "1.5MiB", want: 1572864},
+		{size: "2GiB", want: 2147483648},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseBytes(tt.size)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseBytes(%q) error = %v, want error %v", tt.size, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseBytes(%q) = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+//go:build linux
+
+package app
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FindVolume finds the label and UUID of the volume a path is mounted from, by looking for its device in
+// /proc/self/mountinfo and in the /dev/disk/by-label and /dev/disk/by-uuid links.
+func FindVolume(path string) VolumeInfo {
+	var volume VolumeInfo
+
+	device := mountDevice(path)
+	if device == "" {
+		return volume
+	}
+
+	volume.Label = findDiskLink("/dev/disk/by-label", device)
+	volume.UUID = findDiskLink("/dev/disk/by-uuid", device)
+
+	return volume
+}
+
+// mountDevice returns the device of the mount point containing the path, e.g. /dev/sdb1.
+func mountDevice(path string) string {
+	path, err := filepath.EvalSymlinks(path)
+	if IsError(err) {
+		return ""
+	}
+
+	f, err := os.Open("/proc/self/mountinfo")
+	if IsError(err) {
+		return ""
+	}
+	defer f.Close()
+
+	var mountPoint, device string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// e.g. "36 25 8:17 / /media/card rw,nosuid - vfat /dev/sdb1 rw,uid=1000"
+		fields := strings.Fields(scanner.Text())
+		separator := -1
+		for i, field := range fields {
+			if field == "-" {
+				separator = i
+				break
+			}
+		}
+		if len(fields) < 5 || separator < 0 || separator+2 >= len(fields) {
+			continue
+		}
+
+		point := unescapeMountPath(fields[4])
+		if !isPathInside(path, point) || len(point) < len(mountPoint) {
+			continue
+		}
+		mountPoint, device = point, fields[separator+2]
+	}
+
+	if !strings.HasPrefix(device, "/dev/") {
+		return ""
+	}
+
+	return device
+}
+
+// findDiskLink returns the name of the link of a /dev/disk directory pointing to the device, unescaped.
+func findDiskLink(dir string, device string) string {
+	device, err := filepath.EvalSymlinks(device)
+	if IsError(err) {
+		return ""
+	}
+
+	entries, err := os.ReadDir(dir)
+	if IsError(err) {
+		return ""
+	}
+	for _, entry := range entries {
+		if target, err := filepath.EvalSymlinks(filepath.Join(dir, entry.Name())); !IsError(err) && target == device {
+			return unescapeDiskLink(entry.Name())
+		}
+	}
+
+	return ""
+}
+
+var (
+	regexMountEscape = regexp.MustCompile(`\\[0-7]{3}`)
+	regexUdevEscape  = regexp.MustCompile(`\\x[0-9a-fA-F]{2}`)
+)
+
+// unescapeMountPath unescapes the octal sequences of the mountinfo paths, e.g. "\040" for spaces.
+func unescapeMountPath(path string) string {
+	return regexMountEscape.ReplaceAllStringFunc(path, func(seq string) string {
+		c, _ := strconv.ParseUint(seq[1:], 8, 8)
+		return string(rune(c))
+	})
+}
+
+// unescapeDiskLink unescapes the hexadecimal sequences of the udev links, e.g. "\x20" for spaces.
+func unescapeDiskLink(name string) string {
+	return regexUdevEscape.ReplaceAllStringFunc(name, func(seq string) string {
+		c, _ := strconv.ParseUint(seq[2:], 16, 8)
+		return string(rune(c))
+	})
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package app
+
+// FindVolume is only supported on Linux, the volume is unknown on the other platforms.
+func FindVolume(path string) VolumeInfo {
+	return VolumeInfo{}
+}
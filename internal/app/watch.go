@@ -0,0 +1,284 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultWatchStableTime   = 5 * time.Second
+	DefaultWatchPollInterval = 10 * time.Second
+)
+
+// dirWatcher notifies the paths of the files and directories created or modified in the watched directories.
+type dirWatcher interface {
+	Add(dir string) error
+	Events() <-chan string
+	Close() error
+}
+
+// pendingFile is a file waiting to be stable, before processing it.
+type pendingFile struct {
+	size      int64
+	modTime   time.Time
+	changedAt time.Time
+}
+
+// fileWatch is the state of a running watch.
+type fileWatch struct {
+	params     CmdOptions
+	filter     *fileFilter
+	watcher    dirWatcher
+	stableTime time.Duration
+	watched    map[string]bool
+	pending    map[string]pendingFile
+}
+
+// Watch keeps importing the files of the source directory, as they are added, until the context is cancelled.
+// A file is processed once its size and modification time have not changed for stableTime, and no process has it
+// open for writing. The existing files are processed too. New files are detected with inotify on Linux, or by
+// scanning the source directory every pollInterval on the other platforms.
+func Watch(ctx context.Context, params CmdOptions, stableTime time.Duration, pollInterval time.Duration) (CmdFileStats, error) {
+	stats := newCmdFileStats()
+
+	params, err := withDCIMSource(params)
+	if IsError(err) {
+		return stats, err
+	}
+	params.PairCache = NewPairCache()
+
+	watcher, err := newDirWatcher(params.SrcDir, pollInterval)
+	if IsError(err) {
+		return stats, err
+	}
+	defer watcher.Close()
+
+	w := &fileWatch{
+		params:     params,
+		filter:     newFileFilter(params),
+		watcher:    watcher,
+		stableTime: stableTime,
+		watched:    map[string]bool{},
+		pending:    map[string]pendingFile{},
+	}
+	params.PairCache.useFilter(w.filter)
+	if err := w.scanDir(params.SrcDir); IsError(err) {
+		return stats, err
+	}
+
+	ticker := time.NewTicker(min(time.Second, stableTime/2+time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// the current file is already finished or rolled back, since files are processed in this loop
+			return stats, nil
+		case path := <-watcher.Events():
+			if err := w.enqueue(path); IsError(err) {
+				return stats, err
+			}
+		case <-ticker.C:
+			if err := w.processStableFiles(ctx, &stats); IsError(err) {
+				return stats, err
+			}
+		}
+	}
+}
+
+// scanDir watches a directory and its subdirectories, unless they are excluded, and adds their files to the pending
+// ones.
+func (w *fileWatch) scanDir(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if IsError(err) {
+			return err
+		}
+
+		if !info.IsDir() {
+			w.addPending(path, info)
+			return nil
+		}
+
+		if w.isDestination(path) {
+			return filepath.SkipDir
+		}
+		if reason := w.filter.dirSkipReason(path); reason != "" {
+			Logger.Info("directory skipped", "path", path, "reason", reason)
+			return filepath.SkipDir
+		}
+		if w.watched[path] {
+			return nil
+		}
+
+		if err := w.filter.loadIgnoreFile(path); IsError(err) {
+			return err
+		}
+		if err := w.watcher.Add(path); IsError(err) {
+			return err
+		}
+		w.watched[path] = true
+		Logger.Debug("directory watched", "path", path)
+
+		return nil
+	})
+}
+
+// isDestination tells if a directory is the destination one, which cannot be watched when it's inside the source.
+func (w *fileWatch) isDestination(path string) bool {
+	return path == w.params.DestDir || strings.HasPrefix(path, w.params.DestDir+string(os.PathSeparator))
+}
+
+// enqueue adds a new or modified file to the pending ones, or scans a new directory.
+func (w *fileWatch) enqueue(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if IsError(err) {
+		return err
+	}
+
+	if info.IsDir() {
+		return w.scanDir(path)
+	}
+	w.addPending(path, info)
+
+	return nil
+}
+
+func (w *fileWatch) addPending(path string, info os.FileInfo) {
+	if _, ok := w.pending[path]; ok {
+		return
+	}
+	w.pending[path] = pendingFile{size: info.Size(), modTime: info.ModTime(), changedAt: time.Now()}
+}
+
+// processStableFiles processes the pending files that did not change for the stable time.
+func (w *fileWatch) processStableFiles(ctx context.Context, stats *CmdFileStats) error {
+	now := time.Now()
+
+	for path, file := range w.pending {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			delete(w.pending, path)
+			continue
+		}
+		if IsError(err) {
+			return err
+		}
+
+		if info.Size() != file.size || !info.ModTime().Equal(file.modTime) {
+			w.pending[path] = pendingFile{size: info.Size(), modTime: info.ModTime(), changedAt: now}
+			continue
+		}
+		if now.Sub(file.changedAt) < w.stableTime {
+			continue
+		}
+		if IsOpenForWriting(path) {
+			Logger.Debug("file still being written", "path", path)
+			continue
+		}
+
+		delete(w.pending, path)
+		stats.ScannedSize += info.Size()
+
+		if reason := w.filter.fileSkipReason(path, info); reason != "" {
+			skipFile(stats, path, reason)
+			continue
+		}
+
+		if _, err := tidyUpFile(ctx, w.params, stats, path, info, nil); IsError(err) && !isCancelled(ctx, err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pollWatcher detects the changes by scanning the directory tree periodically, when no native watcher is available.
+type pollWatcher struct {
+	root     string
+	interval time.Duration
+	events   chan string
+	done     chan struct{}
+	once     sync.Once
+	files    map[string]time.Time
+}
+
+func newPollWatcher(root string, interval time.Duration) *pollWatcher {
+	w := &pollWatcher{
+		root:     root,
+		interval: interval,
+		events:   make(chan string),
+		done:     make(chan struct{}),
+	}
+	// the current files are not notified, only the ones that change from now on
+	w.files = w.snapshot()
+	go w.run()
+
+	return w
+}
+
+// Add does nothing, since the whole tree is scanned every time.
+func (w *pollWatcher) Add(dir string) error {
+	return nil
+}
+
+func (w *pollWatcher) Events() <-chan string {
+	return w.events
+}
+
+func (w *pollWatcher) Close() error {
+	w.once.Do(func() { close(w.done) })
+	return nil
+}
+
+func (w *pollWatcher) run() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		files := w.snapshot()
+		for path, modTime := range files {
+			if previous, ok := w.files[path]; ok && previous.Equal(modTime) {
+				continue
+			}
+			select {
+			case w.events <- path:
+			case <-w.done:
+				return
+			}
+		}
+		w.files = files
+	}
+}
+
+// snapshot returns the modification time of every file of the tree.
+func (w *pollWatcher) snapshot() map[string]time.Time {
+	files := map[string]time.Time{}
+	_ = filepath.Walk(w.root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files[path] = info.ModTime()
+		}
+		return nil
+	})
+
+	return files
+}
@@ -0,0 +1,167 @@
+//go:build linux
+
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO
+
+// inotifyWatcher uses the inotify API of Linux to be notified of the new files.
+type inotifyWatcher struct {
+	fd     int      // never taken from the file with Fd(), which would make it blocking again
+	file   *os.File // to read the events
+	events chan string
+	done   chan struct{}
+	once   sync.Once
+	mu     sync.Mutex
+	dirs   map[int32]string
+}
+
+// newDirWatcher uses inotify, or scans the tree periodically if it's not available (e.g. when the limit of
+// watches or instances is reached).
+func newDirWatcher(root string, pollInterval time.Duration) (dirWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if IsError(err) {
+		Logger.Warn("inotify is not available, the source directory will be scanned periodically", "error", err)
+		return newPollWatcher(root, pollInterval), nil
+	}
+
+	// a non-blocking file can be closed while it's being read, which stops the reading goroutine
+	w := &inotifyWatcher{
+		fd:     fd,
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		events: make(chan string),
+		done:   make(chan struct{}),
+		dirs:   map[int32]string{},
+	}
+	go w.run(root)
+
+	return w, nil
+}
+
+func (w *inotifyWatcher) Add(dir string) error {
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, inotifyMask)
+	if IsError(err) {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+
+	w.mu.Lock()
+	w.dirs[int32(wd)] = dir
+	w.mu.Unlock()
+
+	return nil
+}
+
+func (w *inotifyWatcher) Events() <-chan string {
+	return w.events
+}
+
+func (w *inotifyWatcher) Close() error {
+	w.once.Do(func() { close(w.done) })
+	return w.file.Close()
+}
+
+func (w *inotifyWatcher) run(root string) {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+
+	for {
+		n, err := w.file.Read(buf)
+		if IsError(err) {
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			name := strings.TrimRight(string(buf[offset+syscall.SizeofInotifyEvent:offset+syscall.SizeofInotifyEvent+int(event.Len)]), "\x00")
+			offset += syscall.SizeofInotifyEvent + int(event.Len)
+
+			w.mu.Lock()
+			dir, ok := w.dirs[event.Wd]
+			if event.Mask&syscall.IN_IGNORED != 0 {
+				delete(w.dirs, event.Wd)
+			}
+			w.mu.Unlock()
+
+			path := filepath.Join(dir, name)
+			switch {
+			case event.Mask&syscall.IN_Q_OVERFLOW != 0:
+				// some events were lost, the whole tree is scanned again
+				path = root
+			case !ok || name == "":
+				continue
+			}
+
+			if !w.send(path) {
+				return
+			}
+		}
+	}
+}
+
+// send notifies a path, unless the watcher is closed.
+func (w *inotifyWatcher) send(path string) bool {
+	select {
+	case w.events <- path:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// IsOpenForWriting tells if any process has the file open for writing, by checking the open files of every
+// process in /proc. Only the processes of the same user can be checked, unless running as root.
+func IsOpenForWriting(path string) bool {
+	procs, err := os.ReadDir("/proc")
+	if IsError(err) {
+		return false
+	}
+
+	for _, proc := range procs {
+		if _, err := strconv.Atoi(proc.Name()); IsError(err) {
+			continue
+		}
+
+		fdDir := filepath.Join("/proc", proc.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if IsError(err) {
+			continue
+		}
+
+		for _, fd := range fds {
+			if target, err := os.Readlink(filepath.Join(fdDir, fd.Name())); IsError(err) || target != path {
+				continue
+			}
+			if isWriteFlags(filepath.Join("/proc", proc.Name(), "fdinfo", fd.Name())) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isWriteFlags tells if the flags of a /proc/<pid>/fdinfo file have the write or read-write access mode.
+func isWriteFlags(fdInfoPath string) bool {
+	data, err := os.ReadFile(fdInfoPath)
+	if IsError(err) {
+		return false
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, "flags:"); ok {
+			flags, err := strconv.ParseInt(strings.TrimSpace(value), 8, 64)
+			return !IsError(err) && flags&(syscall.O_WRONLY|syscall.O_RDWR) != 0
+		}
+	}
+
+	return false
+}
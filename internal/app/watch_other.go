@@ -0,0 +1,14 @@
+//go:build !linux
+
+package app
+
+import "time"
+
+func newDirWatcher(root string, pollInterval time.Duration) (dirWatcher, error) {
+	return newPollWatcher(root, pollInterval), nil
+}
+
+// IsOpenForWriting cannot be known on the other platforms, so only the file size and modification time are checked.
+func IsOpenForWriting(path string) bool {
+	return false
+}